@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"net/http" 
 	"strconv"
 	"github.com/gorilla/mux"
@@ -14,6 +15,7 @@ func (app *application) getActorListHandler(w http.ResponseWriter, r *http.Reque
 	var input struct {
 		Fullname      string `json:"full_name"`
 		DoramaID      int    `json:"dorama_id"`
+		Role          string `json:"role"`
 		model.Filters
 	}
 
@@ -25,7 +27,9 @@ func (app *application) getActorListHandler(w http.ResponseWriter, r *http.Reque
 
 	input.Fullname = app.readString(qs, "full_name", "")
 
-	input.DoramaID = app.readInt(qs, "dorama_id", 1, v)
+	input.DoramaID = app.readInt(qs, "dorama_id", 0, v)
+	input.Role = app.readString(qs, "role", "")
+	v.Check(input.Role == "" || validator.In(input.Role, model.CreditRoles...), "role", "must be one of actor, director, screenwriter, composer or producer")
 	// Get the page and page_size query string values as integers. Notice that we set
 	// the default page value to 1 and default page_size to 20, and that we pass the
 	// validator instance as the final argument here.
@@ -35,7 +39,7 @@ func (app *application) getActorListHandler(w http.ResponseWriter, r *http.Reque
 	// by the client (which will imply a ascending sort on movie ID).
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 	// Add the supported sort values for this endpoint to the sort safelist.
-	input.Filters.SortSafelist = []string{"id", "full_name","dorama_id", "-id", "-fullname","-dorama_id"}
+	input.Filters.SortSafelist = []string{"id", "full_name", "credit_count", "-id", "-full_name", "-credit_count"}
 
 	// Execute the validation checks on the Filters struct and send a response
 	// containing the errors if necessary.
@@ -47,7 +51,7 @@ func (app *application) getActorListHandler(w http.ResponseWriter, r *http.Reque
 	// parameters.
 	// Accept the metadata struct as a return value.
 	
-	actors, metadata, err := app.models.Actors.GetAll(input.Fullname, input.DoramaID, input.Filters)
+	actors, metadata, err := app.models.Actors.GetAll(input.Fullname, input.DoramaID, input.Role, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -79,6 +83,7 @@ func (app *application) getActorHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	w.Header().Set("ETag", app.etag(actor.Version))
 	app.respondWithJSON(w, http.StatusOK, actor)
 }
 
@@ -91,12 +96,19 @@ func (app *application) createActorHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Actors.Insert(&input)
+	v := validator.New()
+	if model.ValidateActor(v, &input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Actors.Insert(&input, app.contextGetUser(r).ID)
 	if err != nil {
 		app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
 		return
 	}
 
+	w.Header().Set("ETag", app.etag(input.Version))
 	app.respondWithJSON(w, http.StatusCreated, input)
 }
 
@@ -115,6 +127,12 @@ func (app *application) updateActorHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Refuse the update if the client is working from a stale copy of the record.
+	if !app.ifMatch(r, actor.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
 	var input model.Actor
 
 	err = app.readJSON(w, r, &input)
@@ -124,14 +142,110 @@ func (app *application) updateActorHandler(w http.ResponseWriter, r *http.Reques
 	}
  
 	actor.Name = input.Name
-	actor.DoramaID = input.DoramaID
+	actor.BirthDate = input.BirthDate
+	actor.Nationality = input.Nationality
+	actor.Biography = input.Biography
+	actor.Agency = input.Agency
+	actor.Links = input.Links
 	
-	err = app.models.Actors.Update(actor)
+	v := validator.New()
+	if model.ValidateActor(v, actor); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Actors.Update(actor, app.contextGetUser(r).ID)
 	if err != nil {
-		app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", app.etag(actor.Version))
+	app.respondWithJSON(w, http.StatusOK, actor)
+}
+
+// patchActorHandler applies a partial update, keeping the current value of any field
+// that isn't present in the request body.
+func (app *application) patchActorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	actor, err := app.models.Actors.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.ifMatch(r, actor.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Name        *string      `json:"full_name"`
+		BirthDate   *string      `json:"birth_date"`
+		Nationality *string      `json:"nationality"`
+		Biography   *string      `json:"biography"`
+		Agency      *string      `json:"agency"`
+		Links       []model.Link `json:"links"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		actor.Name = *input.Name
+	}
+	if input.BirthDate != nil {
+		actor.BirthDate = *input.BirthDate
+	}
+	if input.Nationality != nil {
+		actor.Nationality = *input.Nationality
+	}
+	if input.Biography != nil {
+		actor.Biography = *input.Biography
+	}
+	if input.Agency != nil {
+		actor.Agency = *input.Agency
+	}
+	if input.Links != nil {
+		actor.Links = input.Links
+	}
+
+	v := validator.New()
+	if model.ValidateActor(v, actor); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Actors.Update(actor, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	w.Header().Set("ETag", app.etag(actor.Version))
 	app.respondWithJSON(w, http.StatusOK, actor)
 }
 
@@ -145,9 +259,25 @@ func (app *application) deleteActorHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Actors.Delete(id)
+	actor, err := app.models.Actors.Get(id)
 	if err != nil {
-		app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
+		app.respondWithError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+
+	if !app.ifMatch(r, actor.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	err = app.models.Actors.Delete(id, actor.Version, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
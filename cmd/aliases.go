@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func (app *application) getAliasListHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	aliases, err := app.models.Aliases.GetAllForDorama(doramaID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"aliases": aliases}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createAliasHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Alias    string `json:"alias"`
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	alias := &model.Alias{
+		DoramaID: doramaID,
+		Alias:    input.Alias,
+		Kind:     input.Kind,
+		Language: input.Language,
+	}
+	if alias.Kind == "" {
+		alias.Kind = "other"
+	}
+
+	v := validator.New()
+	if model.ValidateAlias(v, alias); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Aliases.Insert(alias)
+	if err != nil {
+		app.aliasWriteErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"alias": alias}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateAliasHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r, "alias_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	alias, err := app.models.Aliases.Get(doramaID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Alias    string `json:"alias"`
+		Kind     string `json:"kind"`
+		Language string `json:"language"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	alias.Alias = input.Alias
+	alias.Kind = input.Kind
+	alias.Language = input.Language
+	if alias.Kind == "" {
+		alias.Kind = "other"
+	}
+
+	v := validator.New()
+	if model.ValidateAlias(v, alias); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Aliases.Update(alias)
+	if err != nil {
+		app.aliasWriteErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"alias": alias}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteAliasHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r, "alias_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Aliases.Delete(doramaID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "alias successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// aliasWriteErrorResponse reports the errors that AliasModel.Insert and Update can
+// return.
+func (app *application) aliasWriteErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, model.ErrDuplicateAlias):
+		v.AddError("alias", "this dorama already has this alias")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, model.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
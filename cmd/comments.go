@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// getCommentListHandler shows a page of the top-level comments on a review, each with
+// its thread of replies.
+func (app *application) getCommentListHandler(w http.ResponseWriter, r *http.Request) {
+	var filters model.Filters
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "created_at")
+	filters.SortSafelist = []string{"created_at", "updated_at", "-created_at", "-updated_at"}
+
+	if model.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	comments, metadata, err := app.models.Comments.GetAllForReview(review.ID, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"comments": comments, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createCommentHandler adds a comment to a review, or a reply to another comment when
+// parent_id is given.
+func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ParentID int64  `json:"parent_id"`
+		Body     string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	comment := &model.Comment{
+		ReviewID: review.ID,
+		ParentID: input.ParentID,
+		UserID:   user.ID,
+		UserName: user.Name,
+		Body:     input.Body,
+	}
+
+	v := validator.New()
+	if model.ValidateComment(v, comment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if comment.ParentID != 0 {
+		_, err = app.models.Comments.Get(review.ID, comment.ParentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, model.ErrRecordNotFound):
+				v.AddError("parent_id", "must be a comment on this review")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	err = app.models.Comments.Insert(comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", app.etag(comment.Version))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// patchCommentHandler lets the author of a comment change its text.
+func (app *application) patchCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	if !app.ifMatch(r, comment.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Body *string `json:"body"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Body != nil {
+		comment.Body = *input.Body
+	}
+
+	v := validator.New()
+	if model.ValidateComment(v, comment); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Comments.Update(comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", app.etag(comment.Version))
+	err = app.writeJSON(w, http.StatusOK, envelope{"comment": comment}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteCommentHandler lets the author of a comment withdraw it. Replies to it stay, under
+// a blanked comment.
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	if comment.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err := app.models.Comments.Delete(comment.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "comment successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readComment looks up the comment named by the request path on a review the user can
+// see. If it can't, the error response has already been sent and ok is false.
+func (app *application) readComment(w http.ResponseWriter, r *http.Request) (comment *model.Comment, ok bool) {
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["comment_id"], 10, 64)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	comment, err = app.models.Comments.Get(review.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return comment, true
+}
@@ -28,3 +28,19 @@ func (app *application) contextGetUser(r *http.Request) *model.User {
 	}
 	return user
 }
+
+const tokenContextKey = contextKey("token")
+
+// contextSetToken stores the plaintext authentication token the request was made
+// with, so that handlers can tell which session is the current one.
+func (app *application) contextSetToken(r *http.Request, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), tokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// contextGetToken returns the plaintext authentication token of the request, or the
+// empty string for anonymous requests.
+func (app *application) contextGetToken(r *http.Request) string {
+	token, _ := r.Context().Value(tokenContextKey).(string)
+	return token
+}
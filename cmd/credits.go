@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func (app *application) getDoramaCastHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Make sure the dorama exists, so that an unknown ID is reported as a 404 rather
+	// than an empty cast list.
+	_, err = app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	cast, err := app.models.Doramas.GetCredits(id, model.RoleActor)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"cast": cast}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getDoramaCreditsHandler lists the cast and crew of a dorama, optionally narrowed
+// down to one role with ?role=.
+func (app *application) getDoramaCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	role := app.readString(r.URL.Query(), "role", "")
+
+	v := validator.New()
+	v.Check(role == "" || validator.In(role, model.CreditRoles...), "role", "must be one of actor, director, screenwriter, composer or producer")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	credits, err := app.models.Doramas.GetCredits(id, role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"credits": credits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) setDoramaCastMemberHandler(w http.ResponseWriter, r *http.Request) {
+	app.setDoramaCredit(w, r, model.RoleActor, "cast_member")
+}
+
+func (app *application) setDoramaCreditHandler(w http.ResponseWriter, r *http.Request) {
+	app.setDoramaCredit(w, r, mux.Vars(r)["role"], "credit")
+}
+
+// setDoramaCredit credits the person in the request path in a dorama, in the given
+// role, and responds with the credit under key.
+func (app *application) setDoramaCredit(w http.ResponseWriter, r *http.Request, role, key string) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	actorID, err := app.readIDParam(r, "actor_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		CharacterName string `json:"character_name"`
+		BillingOrder  int    `json:"billing_order"`
+		IsLead        bool   `json:"is_lead"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	dorama, err := app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	actor, err := app.models.Actors.Get(actorID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	credit := &model.Credit{
+		DoramaID:      dorama.DoramaId,
+		ActorID:       actor.ActorId,
+		Role:          role,
+		ActorName:     actor.Name,
+		CharacterName: input.CharacterName,
+		BillingOrder:  input.BillingOrder,
+		IsLead:        input.IsLead,
+	}
+
+	v := validator.New()
+	if model.ValidateCredit(v, credit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Doramas.SetCredit(credit, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{key: credit}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeDoramaCastMemberHandler(w http.ResponseWriter, r *http.Request) {
+	app.removeDoramaCredit(w, r, model.RoleActor, "cast member successfully removed")
+}
+
+func (app *application) removeDoramaCreditHandler(w http.ResponseWriter, r *http.Request) {
+	app.removeDoramaCredit(w, r, mux.Vars(r)["role"], "credit successfully removed")
+}
+
+func (app *application) removeDoramaCredit(w http.ResponseWriter, r *http.Request, role, message string) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	actorID, err := app.readIDParam(r, "actor_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Doramas.RemoveCredit(doramaID, actorID, role, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": message}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getActorFilmographyHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	role := app.readString(r.URL.Query(), "role", "")
+
+	v := validator.New()
+	v.Check(role == "" || validator.In(role, model.CreditRoles...), "role", "must be one of actor, director, screenwriter, composer or producer")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Actors.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	filmography, err := app.models.Actors.GetFilmography(id, role, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"filmography": filmography}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
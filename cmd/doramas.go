@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"github.com/gorilla/mux"
 	"github.com/makooster/MCA/pkg/model"
 	"github.com/makooster/MCA/pkg/validator"
@@ -14,7 +16,12 @@ func (app *application) getDoramaListHandler(w http.ResponseWriter, r *http.Requ
 	var input struct {
 		Title    string `json:"title"`
 		ReleaseYear int `json:"release_year"`
+		Status      string `json:"status"`
 		Duration    int `json:"duration"`
+		GenreIDs    []int64 `json:"genres"`
+		GenresMatch string  `json:"genres_match"`
+		CompanyIDs  []int64 `json:"companies"`
+		Countries   []string `json:"countries"`
 		model.Filters
 	}
 
@@ -31,6 +38,26 @@ func (app *application) getDoramaListHandler(w http.ResponseWriter, r *http.Requ
 
 	input.ReleaseYear = app.readInt(qs, "release_year", 1, v)
 
+	// ?status=airing lists what is on air right now, ?status=announced what is coming up.
+	input.Status = app.readString(qs, "status", "")
+	v.Check(input.Status == "" || validator.In(input.Status, model.StatusAnnounced, model.StatusAiring, model.StatusFinished, model.StatusCancelled), "status", "must be one of announced, airing, finished or cancelled")
+
+	// Genres are given as a comma-separated list of IDs. By default a dorama matches if
+	// it has any of them; genres_match=all narrows this down to doramas that have every
+	// one of the listed genres.
+	input.GenreIDs = app.readIDCSV(qs, "genres", []int64{}, v)
+	input.GenresMatch = app.readString(qs, "genres_match", "any")
+	v.Check(validator.In(input.GenresMatch, "any", "all"), "genres_match", "must be either any or all")
+
+	// Networks and studios are filtered by ID, countries of origin by their ISO code,
+	// e.g. ?companies=3&countries=KR,CN.
+	input.CompanyIDs = app.readIDCSV(qs, "companies", []int64{}, v)
+	input.Countries = app.readCSV(qs, "countries", []string{})
+	for i, code := range input.Countries {
+		input.Countries[i] = strings.ToUpper(strings.TrimSpace(code))
+		v.Check(validator.Matches(input.Countries[i], model.CountryCodeRX), "countries", "must be a comma-separated list of country codes")
+	}
+
 	// input.Duration = app.readInt(qs, "duration", 1, v)
 	
 
@@ -43,7 +70,11 @@ func (app *application) getDoramaListHandler(w http.ResponseWriter, r *http.Requ
 	// by the client (which will imply a ascending sort on movie ID).
 	input.Filters.Sort = app.readString(qs, "sort", "dorama_id")
 	// Add the supported sort values for this endpoint to the sort safelist.
-	input.Filters.SortSafelist = []string{"dorama_id", "title","release_year", "-dorama_id", "-title","-release_year"}
+	input.Filters.SortSafelist = []string{"dorama_id", "title","release_year", "start_date", "rating", "-dorama_id", "-title","-release_year", "-start_date", "-rating"}
+
+	// Titles and descriptions are localized into the language the client asked for,
+	// with ?lang= taking precedence over Accept-Language.
+	languages := app.languages(r, v)
 
 	// Execute the validation checks on the Filters struct and send a response
 	// containing the errors if necessary.
@@ -55,12 +86,19 @@ func (app *application) getDoramaListHandler(w http.ResponseWriter, r *http.Requ
 	// parameters.
 	// Accept the metadata struct as a return value.
 	
-	doramas, metadata, err := app.models.Doramas.GetAll(input.Title, input.ReleaseYear,input.Filters)
+	doramas, metadata, err := app.models.Doramas.GetAll(input.Title, input.ReleaseYear, input.Status, input.GenreIDs, input.GenresMatch == "all", input.CompanyIDs, input.Countries, app.contextGetUser(r).MaturityLevel, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	err = app.models.Translations.LocalizeDoramas(doramas, languages)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+
 	// Send a JSON response containing the movie data.
 	// Include the metadata in the response envelope.
 	
@@ -81,12 +119,27 @@ func (app *application) getDoramaHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	drama, err := app.models.Doramas.Get(id)
+	v := validator.New()
+	languages := app.languages(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	drama, err := app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
 	if err != nil {
 		app.respondWithError(w, http.StatusNotFound, "404 Not Found")
 		return
 	}
 
+	err = app.models.Translations.LocalizeDoramas([]*model.Dorama{drama}, languages)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("ETag", app.localizedETag(drama.Version, drama.Language))
 	app.respondWithJSON(w, http.StatusOK, drama)
 }
 
@@ -100,13 +153,25 @@ func (app *application) createDoramaHandler(w http.ResponseWriter, r *http.Reque
 		app.badRequestResponse(w, r, err)
 		return
 	}
+
+	// New doramas start out as announced unless the client says otherwise.
+	if input.Status == "" {
+		input.Status = model.StatusAnnounced
+	}
+
+	v := validator.New()
+	if model.ValidateDorama(v, &input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 	
-	err = app.models.Doramas.Insert(&input)
+	err = app.models.Doramas.Insert(&input, app.contextGetUser(r).ID)
 	if err != nil {
-		app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
+		app.doramaWriteErrorResponse(w, r, v, err)
 		return
 	}
 
+	w.Header().Set("ETag", app.etag(input.Version))
 	app.respondWithJSON(w, http.StatusCreated, input)
 }
 
@@ -119,12 +184,18 @@ func (app *application) updateDoramaHandler(w http.ResponseWriter, r *http.Reque
         return
     }
 
-    dorama, err := app.models.Doramas.Get(id)
+    dorama, err := app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
     if err != nil {
         app.respondWithError(w, http.StatusNotFound, "404 Not Found")
         return
     }
 
+	// Refuse the update if the client is working from a stale copy of the record.
+	if !app.ifMatch(r, dorama.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
     var input model.Dorama
     err = app.readJSON(w, r, &input)
     if err != nil {
@@ -135,19 +206,145 @@ func (app *application) updateDoramaHandler(w http.ResponseWriter, r *http.Reque
     dorama.Title = input.Title
     dorama.Description = input.Description
     dorama.ReleaseYear = input.ReleaseYear
-    dorama.MainActors = input.MainActors
     dorama.Duration = input.Duration
-    dorama.GenreId = input.GenreId
+    dorama.Status = input.Status
+    dorama.StartDate = input.StartDate
+    dorama.EndDate = input.EndDate
+    dorama.GenreIDs = input.GenreIDs
+    dorama.CompanyIDs = input.CompanyIDs
+    dorama.CountryCodes = input.CountryCodes
+    dorama.RatingSystem = input.RatingSystem
+    dorama.ContentRating = input.ContentRating
+    dorama.ContentWarnings = input.ContentWarnings
+
+	// A status left out of a full update defaults to announced, as it does on create,
+	// so that clients written before doramas had a status keep working.
+	if dorama.Status == "" {
+		dorama.Status = model.StatusAnnounced
+	}
+
+	v := validator.New()
+	if model.ValidateDorama(v, dorama); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 
-    err = app.models.Doramas.Update(dorama)
+    err = app.models.Doramas.Update(dorama, app.contextGetUser(r).ID)
     if err != nil {
-        app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
+		app.doramaWriteErrorResponse(w, r, v, err)
         return
     }
 
+	w.Header().Set("ETag", app.etag(dorama.Version))
     app.respondWithJSON(w, http.StatusOK, dorama)
 }
 
+// patchDoramaHandler applies a partial update. Fields missing from the request body
+// (or sent as null) keep their current values, and the merged record is validated
+// again before it is saved.
+func (app *application) patchDoramaHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	dorama, err := app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.ifMatch(r, dorama.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// Use pointers so that we can tell a field that wasn't sent apart from one that was
+	// sent with its zero value. The slices are already nil when absent.
+	var input struct {
+		Title        *string  `json:"title"`
+		Description  *string  `json:"description"`
+		ReleaseYear  *int     `json:"release_year"`
+		Duration     *int     `json:"duration"`
+		Status       *string  `json:"status"`
+		StartDate    *string  `json:"start_date"`
+		EndDate      *string  `json:"end_date"`
+		GenreIDs     []int64  `json:"genre_ids"`
+		CompanyIDs   []int64  `json:"company_ids"`
+		CountryCodes []string `json:"country_codes"`
+		RatingSystem    *string  `json:"rating_system"`
+		ContentRating   *string  `json:"content_rating"`
+		ContentWarnings []string `json:"content_warnings"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Title != nil {
+		dorama.Title = *input.Title
+	}
+	if input.Description != nil {
+		dorama.Description = *input.Description
+	}
+	if input.ReleaseYear != nil {
+		dorama.ReleaseYear = *input.ReleaseYear
+	}
+	if input.Duration != nil {
+		dorama.Duration = *input.Duration
+	}
+	if input.Status != nil {
+		dorama.Status = *input.Status
+	}
+	if input.StartDate != nil {
+		dorama.StartDate = *input.StartDate
+	}
+	if input.EndDate != nil {
+		dorama.EndDate = *input.EndDate
+	}
+	if input.GenreIDs != nil {
+		dorama.GenreIDs = input.GenreIDs
+	}
+	if input.CompanyIDs != nil {
+		dorama.CompanyIDs = input.CompanyIDs
+	}
+	if input.CountryCodes != nil {
+		dorama.CountryCodes = input.CountryCodes
+	}
+	if input.RatingSystem != nil {
+		dorama.RatingSystem = *input.RatingSystem
+	}
+	if input.ContentRating != nil {
+		dorama.ContentRating = *input.ContentRating
+	}
+	if input.ContentWarnings != nil {
+		dorama.ContentWarnings = input.ContentWarnings
+	}
+
+	v := validator.New()
+	if model.ValidateDorama(v, dorama); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Doramas.Update(dorama, app.contextGetUser(r).ID)
+	if err != nil {
+		app.doramaWriteErrorResponse(w, r, v, err)
+		return
+	}
+
+	w.Header().Set("ETag", app.etag(dorama.Version))
+	app.respondWithJSON(w, http.StatusOK, dorama)
+}
+
 func (app *application) deleteDoramaHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	param := vars["id"]
@@ -158,11 +355,47 @@ func (app *application) deleteDoramaHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Doramas.Delete(id)
+	dorama, err := app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		app.respondWithError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+
+	if !app.ifMatch(r, dorama.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	err = app.models.Doramas.Delete(id, dorama.Version, app.contextGetUser(r).ID)
 	if err != nil {
-		app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	app.respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
-}
\ No newline at end of file
+}
+
+// doramaWriteErrorResponse reports the errors that DoramaModel.Insert and Update can
+// return, turning unknown associations into validation errors on the right field.
+func (app *application) doramaWriteErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, model.ErrUnknownGenre):
+		v.AddError("genre_ids", "must only contain existing genres")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, model.ErrUnknownCompany):
+		v.AddError("company_ids", "must only contain existing companies")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, model.ErrUnknownCountry):
+		v.AddError("country_codes", "must only contain existing countries")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, model.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func (app *application) getEpisodeListHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// An optional season query string value narrows the list down to a single season.
+	v := validator.New()
+	season := app.readInt(r.URL.Query(), "season", 0, v)
+	v.Check(season >= 0, "season", "must not be negative")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	episodes, err := app.models.Episodes.GetAllForDorama(doramaID, season)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"episodes": episodes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		SeasonNumber int    `json:"season_number"`
+		Number       int    `json:"episode_number"`
+		Title        string `json:"title"`
+		AirDate      string `json:"air_date"`
+		Runtime      int    `json:"runtime"`
+		Synopsis     string `json:"synopsis"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	episode := &model.Episode{
+		DoramaID:     doramaID,
+		SeasonNumber: input.SeasonNumber,
+		Number:       input.Number,
+		Title:        input.Title,
+		AirDate:      input.AirDate,
+		Runtime:      input.Runtime,
+		Synopsis:     input.Synopsis,
+	}
+
+	v := validator.New()
+	if model.ValidateEpisode(v, episode); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Episodes.Insert(episode)
+	if err != nil {
+		app.episodeWriteErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"episode": episode}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r, "episode_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	episode, err := app.models.Episodes.Get(doramaID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"episode": episode}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r, "episode_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	episode, err := app.models.Episodes.Get(doramaID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		SeasonNumber int    `json:"season_number"`
+		Number       int    `json:"episode_number"`
+		Title        string `json:"title"`
+		AirDate      string `json:"air_date"`
+		Runtime      int    `json:"runtime"`
+		Synopsis     string `json:"synopsis"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	episode.SeasonNumber = input.SeasonNumber
+	episode.Number = input.Number
+	episode.Title = input.Title
+	episode.AirDate = input.AirDate
+	episode.Runtime = input.Runtime
+	episode.Synopsis = input.Synopsis
+
+	v := validator.New()
+	if model.ValidateEpisode(v, episode); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Episodes.Update(episode)
+	if err != nil {
+		app.episodeWriteErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"episode": episode}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteEpisodeHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r, "episode_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Episodes.Delete(doramaID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "episode successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// episodeWriteErrorResponse reports the errors that EpisodeModel.Insert and Update can
+// return, turning constraint violations into validation errors on the right field.
+func (app *application) episodeWriteErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, model.ErrDuplicateEpisode):
+		v.AddError("episode_number", "this season already has an episode with this number")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, model.ErrUnknownSeason):
+		v.AddError("season_number", "this dorama has no season with this number")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, model.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
@@ -68,6 +71,27 @@ func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Requ
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+// The preconditionFailedResponse() method is used when the client's If-Match header
+// doesn't match the current version of the record it is trying to change.
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been modified since you last retrieved it, please fetch it again"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+// The requestTooLargeResponse() method is used when an upload is bigger than the
+// configured limit.
+func (app *application) requestTooLargeResponse(w http.ResponseWriter, r *http.Request, limit int64) {
+	message := fmt.Sprintf("the upload must not be larger than %d bytes", limit)
+	app.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+}
+
+// The unsupportedMediaTypeResponse() method is used when an uploaded file isn't one of
+// the image types we accept.
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the upload must be a JPEG, PNG or GIF image"
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
@@ -92,4 +116,11 @@ func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.R
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
-}
\ No newline at end of file
+}
+// The rateLimitExceededResponse() method is used when a client has made too many
+// requests of a kind, and tells it when it may try again.
+func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	message := "rate limit exceeded, please try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
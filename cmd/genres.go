@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"github.com/gorilla/mux"
@@ -37,6 +38,8 @@ func (app *application) getGenresListHandler(w http.ResponseWriter, r *http.Requ
 	// Add the supported sort values for this endpoint to the sort safelist.
 	input.Filters.SortSafelist = []string{"genre_id", "genre_name","-genre_id", "-genre_name"}
 
+	languages := app.languages(r, v)
+
 	// Execute the validation checks on the Filters struct and send a response
 	// containing the errors if necessary.
 	if model.ValidateFilters(v, input.Filters); !v.Valid() {
@@ -47,12 +50,19 @@ func (app *application) getGenresListHandler(w http.ResponseWriter, r *http.Requ
 	// parameters.
 	// Accept the metadata struct as a return value.
 	
-	genres, metadata, err := app.models.Actors.GetAll(input.GenreName, input.GenreID, input.Filters)
+	genres, metadata, err := app.models.Genres.GetAll(input.GenreName, input.GenreID, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	err = app.models.Translations.LocalizeGenres(genres, languages)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+
 	// Send a JSON response containing the movie data.
 	// Include the metadata in the response envelope.
 	
@@ -69,21 +79,36 @@ func (app *application) getGenreHandler(w http.ResponseWriter, r *http.Request)
 
 	id, err := strconv.Atoi(param)
 	if err != nil || id < 1 {
-		app.respondWithError(w, http.StatusBadRequest, "Invalid actor ID")
+		app.respondWithError(w, http.StatusBadRequest, "Invalid genre ID")
 		return
 	}
 
-	actor, err := app.models.Actors.Get(id)
+	v := validator.New()
+	languages := app.languages(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	genre, err := app.models.Genres.Get(id)
 	if err != nil {
 		app.respondWithError(w, http.StatusNotFound, "404 Not Found")
 		return
 	}
 
-	app.respondWithJSON(w, http.StatusOK, actor)
+	err = app.models.Translations.LocalizeGenres([]*model.Genre{genre}, languages)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("ETag", app.localizedETag(genre.Version, genre.Language))
+	app.respondWithJSON(w, http.StatusOK, genre)
 }
 
 func (app *application) createGenreHandler(w http.ResponseWriter, r *http.Request) {
-	var input model.Actor
+	var input model.Genre
 
 	err := app.readJSON(w, r, &input)
 	if err != nil {
@@ -91,12 +116,19 @@ func (app *application) createGenreHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Actors.Insert(&input)
+	v := validator.New()
+	if model.ValidateGenre(v, &input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Insert(&input, app.contextGetUser(r).ID)
 	if err != nil {
 		app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
 		return
 	}
 
+	w.Header().Set("ETag", app.etag(input.Version))
 	app.respondWithJSON(w, http.StatusCreated, input)
 }
 
@@ -115,6 +147,12 @@ func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Refuse the update if the client is working from a stale copy of the record.
+	if !app.ifMatch(r, genre.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
 	var input model.Genre
 
 	err = app.readJSON(w, r, &input)
@@ -126,12 +164,84 @@ func (app *application) updateGenreHandler(w http.ResponseWriter, r *http.Reques
 	genre.GenreName = input.GenreName
 	
 	
-	err = app.models.Genres.Update(genre)
+	v := validator.New()
+	if model.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Update(genre, app.contextGetUser(r).ID)
 	if err != nil {
-		app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	w.Header().Set("ETag", app.etag(genre.Version))
+	app.respondWithJSON(w, http.StatusOK, genre)
+}
+
+// patchGenreHandler applies a partial update, keeping the current value of any field
+// that isn't present in the request body.
+func (app *application) patchGenreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	genre, err := app.models.Genres.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.ifMatch(r, genre.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		GenreName *string `json:"genre_name"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.GenreName != nil {
+		genre.GenreName = *input.GenreName
+	}
+
+	v := validator.New()
+	if model.ValidateGenre(v, genre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Genres.Update(genre, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", app.etag(genre.Version))
 	app.respondWithJSON(w, http.StatusOK, genre)
 }
 
@@ -145,11 +255,71 @@ func (app *application) deleteGenreHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Actors.Delete(id)
+	genre, err := app.models.Genres.Get(id)
 	if err != nil {
-		app.respondWithError(w, http.StatusInternalServerError, "500 Internal Server Error")
+		app.respondWithError(w, http.StatusNotFound, "404 Not Found")
+		return
+	}
+
+	if !app.ifMatch(r, genre.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	err = app.models.Genres.Delete(id, genre.Version, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	app.respondWithJSON(w, http.StatusOK, map[string]string{"result": "success"})
 }
+
+func (app *application) getDoramaGenresHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	languages := app.languages(r, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	genres, err := app.models.Genres.GetAllForDorama(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Translations.LocalizeGenres(genres, languages)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"genres": genres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
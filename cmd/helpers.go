@@ -5,29 +5,68 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
-	"github.com/julienschmidt/httprouter"
+	"github.com/gorilla/mux"
 	"github.com/makooster/MCA/pkg/validator"
 )
 
 // Define an envelope type.
 type envelope map[string]interface{}
 
-// Retrieve the "id" URL parameter from the current request context, then convert it to
-// an integer and return it. If the operation isn't successful, return 0 and an error.
-func (app *application) readIDParam(r *http.Request) (int64, error) {
-	params := httprouter.ParamsFromContext(r.Context())
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+// Retrieve the named URL parameter from the route variables, then convert it to an
+// integer and return it. If the operation isn't successful, return 0 and an error.
+func (app *application) readIDParam(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)[name])
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 	return id, nil
 }
 
+// The etag() helper formats a record version as a strong entity tag, suitable for the
+// ETag response header.
+func (app *application) etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// The localizedETag() helper returns the ETag for a record shown in the given language,
+// or as stored when language is empty. A translation can change while the record's
+// version stays the same, so a translated representation only gets a weak tag.
+func (app *application) localizedETag(version int, language string) string {
+	if language == "" {
+		return app.etag(version)
+	}
+	return "W/" + app.etag(version)
+}
+
+// The ifMatch() helper reports whether the request's If-Match header allows a write to
+// a record at the given version. A missing header or "*" always matches; otherwise one
+// of the comma-separated entity tags, across any number of If-Match headers, must be the
+// record's current ETag. If-Match uses the strong comparison, so weak tags (W/"3") never
+// match.
+func (app *application) ifMatch(r *http.Request, version int) bool {
+	headers := r.Header.Values("If-Match")
+	if len(headers) == 0 {
+		return true
+	}
+
+	current := app.etag(version)
+	for _, header := range headers {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || tag == current {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Define a writeJSON() helper for sending responses. This takes the destination
 // http.ResponseWriter, the HTTP status code to send, the data to encode to JSON, and a
 // header map containing any additional HTTP headers we want to include in the response.
@@ -147,19 +186,44 @@ func (app *application) readString(qs url.Values, key string, defaultValue strin
 // The readCSV() helper reads a string value from the query string and then splits it
 // into a slice on the comma character. If no matching key could be found, it returns
 // the provided default value.
-
-// func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
-// 	// Extract the value from the query string.
-// 	csv := qs.Get(key)
+func (app *application) readCSV(qs url.Values, key string, defaultValue []string) []string {
+	// Extract the value from the query string.
+	csv := qs.Get(key)
 	
-// 	// If no key exists (or the value is empty) then return the default value.
-// 	if csv == "" {
-// 		return defaultValue
-// 	}
+	// If no key exists (or the value is empty) then return the default value.
+	if csv == "" {
+		return defaultValue
+	}
+
+	// Otherwise parse the value into a []string slice and return it.
+	return strings.Split(csv, ",")
+}
 
-// 	// Otherwise parse the value into a []string slice and return it.
-// 	return strings.Split(csv, ",")
-// }
+// The readIDCSV() helper reads a comma-separated list of IDs from the query string. If
+// no matching key could be found it returns the provided default value. If any of the
+// values isn't a positive integer, then we record an error message in the provided
+// Validator instance. An ID that is listed more than once is only returned once.
+func (app *application) readIDCSV(qs url.Values, key string, defaultValue []int64, v *validator.Validator) []int64 {
+	values := app.readCSV(qs, key, nil)
+	if values == nil {
+		return defaultValue
+	}
+
+	seen := make(map[int64]bool, len(values))
+	ids := make([]int64, 0, len(values))
+	for _, value := range values {
+		id, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || id < 1 {
+			v.AddError(key, "must be a comma-separated list of IDs")
+			return defaultValue
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
 
 // The readInt() helper reads a string value from the query string and converts it to an
 // integer before returning. If no matching key could be found it returns the provided
@@ -183,4 +247,31 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	}
 	// Otherwise, return the converted integer value.
 	return i
-}
\ No newline at end of file
+}
+// background runs fn in a goroutine that the server waits for on shutdown. A panic in
+// fn is logged rather than taking the whole server down.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Println(fmt.Errorf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// clientIP returns the IP address the request came from. Forwarding headers aren't
+// trusted, since any client can set them.
+func (app *application) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func TestReadIDCSV(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		query string
+		want  []int64
+		valid bool
+	}{
+		{"", []int64{}, true},
+		{"genres=3", []int64{3}, true},
+		{"genres=3,1, 2", []int64{3, 1, 2}, true},
+		{"genres=3,1,3", []int64{3, 1}, true},
+		{"genres=3,abc", []int64{}, false},
+		{"genres=3,0", []int64{}, false},
+		{"genres=-1", []int64{}, false},
+		{"genres=3,,1", []int64{}, false},
+	}
+
+	for _, tt := range tests {
+		qs, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v := validator.New()
+		got := app.readIDCSV(qs, "genres", []int64{}, v)
+		if !reflect.DeepEqual(got, tt.want) || v.Valid() != tt.valid {
+			t.Errorf("readIDCSV(%q) = %v, valid %t; want %v, valid %t", tt.query, got, v.Valid(), tt.want, tt.valid)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		name    string
+		headers []string
+		want    bool
+	}{
+		{"no header", nil, true},
+		{"wildcard", []string{"*"}, true},
+		{"current", []string{`"3"`}, true},
+		{"stale", []string{`"2"`}, false},
+		{"weak current", []string{`W/"3"`}, false},
+		{"weak stale", []string{`W/"2"`}, false},
+		{"list", []string{`"1", "2" ,"3"`}, true},
+		{"list without current", []string{`"1", W/"2"`}, false},
+		{"repeated headers", []string{`"1"`, `"3"`}, true},
+		{"repeated headers with weak current", []string{`"1"`, `W/"3"`}, false},
+		{"unquoted", []string{`3`}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/", nil)
+			for _, header := range tt.headers {
+				r.Header.Add("If-Match", header)
+			}
+
+			if got := app.ifMatch(r, 3); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalizedETag(t *testing.T) {
+	app := &application{}
+
+	if got := app.localizedETag(3, ""); got != `"3"` {
+		t.Errorf("as stored: got %s; want a strong tag", got)
+	}
+	if got := app.localizedETag(3, "ko"); got != `W/"3"` {
+		t.Errorf("translated: got %s; want a weak tag", got)
+	}
+}
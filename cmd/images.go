@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/makooster/MCA/pkg/images"
+	"github.com/makooster/MCA/pkg/model"
+)
+
+// Thumbnails are scaled down to fit within these dimensions, which suit a 2:3 poster.
+const (
+	thumbnailWidth  = 320
+	thumbnailHeight = 480
+)
+
+func (app *application) uploadDoramaPosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	url, thumbnailURL, ok := app.storeUploadedImage(w, r, fmt.Sprintf("doramas/%d/poster", id))
+	if !ok {
+		return
+	}
+
+	err = app.models.Doramas.SetPoster(id, url, thumbnailURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"poster_url": url, "poster_thumbnail_url": thumbnailURL}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteDoramaPosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Doramas.SetPoster(id, "", "")
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.deleteStoredImage(r.Context(), fmt.Sprintf("doramas/%d/poster", id))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "poster successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) uploadActorPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Actors.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	url, thumbnailURL, ok := app.storeUploadedImage(w, r, fmt.Sprintf("actors/%d/photo", id))
+	if !ok {
+		return
+	}
+
+	err = app.models.Actors.SetPhoto(id, url, thumbnailURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"photo_url": url, "photo_thumbnail_url": thumbnailURL}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteActorPhotoHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Actors.SetPhoto(id, "", "")
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.deleteStoredImage(r.Context(), fmt.Sprintf("actors/%d/photo", id))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "photo successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// storeUploadedImage reads the "image" field of a multipart request, checks it, and
+// stores it under key together with a thumbnail under key + "_thumb". It returns the
+// URLs of both, with a version suffix so that clients don't keep showing a cached copy
+// of the image being replaced. If anything goes wrong, the error response has already
+// been sent and ok is false.
+func (app *application) storeUploadedImage(w http.ResponseWriter, r *http.Request, key string) (url, thumbnailURL string, ok bool) {
+	limit := app.config.storage.maxUploadSize
+
+	// Leave some room on top of the limit for the multipart boundaries and headers, so
+	// that an image of exactly the maximum size still gets through.
+	r.Body = http.MaxBytesReader(w, r.Body, limit+64<<10)
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.requestTooLargeResponse(w, r, limit)
+		default:
+			app.badRequestResponse(w, r, errors.New("the request must be multipart/form-data with an image field"))
+		}
+		return "", "", false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return "", "", false
+	}
+	if int64(len(data)) > limit {
+		app.requestTooLargeResponse(w, r, limit)
+		return "", "", false
+	}
+
+	img, err := images.Decode(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, images.ErrTooLarge):
+			app.requestTooLargeResponse(w, r, limit)
+		default:
+			app.unsupportedMediaTypeResponse(w, r)
+		}
+		return "", "", false
+	}
+
+	thumbnail, err := img.Thumbnail(thumbnailWidth, thumbnailHeight)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return "", "", false
+	}
+
+	err = app.storage.Put(r.Context(), key, bytes.NewReader(data), img.ContentType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return "", "", false
+	}
+
+	err = app.storage.Put(r.Context(), key+"_thumb", bytes.NewReader(thumbnail), "image/jpeg")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return "", "", false
+	}
+
+	version := "?v=" + strconv.FormatInt(time.Now().Unix(), 10)
+	return app.storage.URL(key) + version, app.storage.URL(key+"_thumb") + version, true
+}
+
+// deleteStoredImage removes an image stored by storeUploadedImage, and its thumbnail.
+func (app *application) deleteStoredImage(ctx context.Context, key string) error {
+	err := app.storage.Delete(ctx, key)
+	if err != nil {
+		return err
+	}
+	return app.storage.Delete(ctx, key+"_thumb")
+}
+
+// serveImages serves the files under root, without the directory listings that
+// http.FileServer would otherwise show.
+func (app *application) serveImages(root string) http.Handler {
+	files := http.FileServer(http.Dir(root))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			app.notFoundResponse(w, r)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// storageURLPath returns the path of a -storage-url without its trailing slash, or ""
+// if it can't be parsed or has no path. Images can't be served from the root, where they
+// would shadow the API, so main refuses to start with such a URL.
+func storageURLPath(storageURL string) string {
+	u, err := url.Parse(storageURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// The languages() helper works out which languages a response should be localized
+// into, most preferred first. An explicit ?lang= query string value (a comma-separated
+// list) wins over the Accept-Language header; either way the configured fallback chain
+// is tried last. Regional tags such as "en-US" are followed by their base language, so
+// that an "en" translation is still found.
+func (app *application) languages(r *http.Request, v *validator.Validator) []string {
+	var requested []string
+
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		for _, tag := range strings.Split(lang, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if !validator.Matches(tag, model.LanguageRX) {
+				v.AddError("lang", "must be a comma-separated list of language tags")
+				return nil
+			}
+			requested = append(requested, tag)
+		}
+	} else {
+		requested = parseAcceptLanguage(r.Header.Get("Accept-Language"))
+	}
+
+	var languages []string
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		if !seen[tag] {
+			seen[tag] = true
+			languages = append(languages, tag)
+		}
+	}
+
+	for _, tag := range requested {
+		add(tag)
+		if i := strings.Index(tag, "-"); i > 0 {
+			add(tag[:i])
+		}
+	}
+	for _, tag := range app.config.languages.fallback {
+		add(tag)
+	}
+
+	return languages
+}
+
+// parseAcceptLanguage returns the language tags in an Accept-Language header ordered
+// by their quality value. The wildcard, tags with q=0 and anything malformed are
+// dropped, since they can't be matched against a stored translation.
+func parseAcceptLanguage(header string) []string {
+	type weightedTag struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weightedTag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if !validator.Matches(tag, model.LanguageRX) {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				value, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					value = 0
+				}
+				q = value
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		tags = append(tags, weightedTag{tag: tag, q: q})
+	}
+
+	// A stable sort keeps the client's own order between tags of equal weight.
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	languages := make([]string, len(tags))
+	for i, t := range tags {
+		languages[i] = t.tag
+	}
+	return languages
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"ko", []string{"ko"}},
+		{"ko-KR, en;q=0.8", []string{"ko-kr", "en"}},
+		{"en;q=0.5, ja;q=0.9, ko", []string{"ko", "ja", "en"}},
+		{"en, ru", []string{"en", "ru"}},
+		{"fr;q=0.7, de;q=0.7", []string{"fr", "de"}},
+		{"*, en;q=0.1", []string{"en"}},
+		{"en;q=0, ru", []string{"ru"}},
+		{"en;q=bogus, ru", []string{"ru"}},
+		{"not a tag!, ja", []string{"ja"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptLanguage(%q) = %q; want %q", tt.header, got, tt.want)
+		}
+	}
+}
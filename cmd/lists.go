@@ -0,0 +1,474 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// listSortSafelist holds the sort values accepted by every endpoint that pages through
+// lists.
+var listSortSafelist = []string{"title", "created_at", "updated_at", "follower_count", "-title", "-created_at", "-updated_at", "-follower_count"}
+
+// discoverListsHandler pages through the public lists of every user.
+func (app *application) discoverListsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title string
+		model.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Title = app.readString(qs, "title", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-follower_count")
+	input.Filters.SortSafelist = listSortSafelist
+
+	if model.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	lists, metadata, err := app.models.Lists.GetAllPublic(input.Title, app.contextGetUser(r).MaturityLevel, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"lists": lists, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getMyListsHandler(w http.ResponseWriter, r *http.Request) {
+	app.userListsResponse(w, r, app.models.Lists.GetAllForUser)
+}
+
+func (app *application) getFollowedListsHandler(w http.ResponseWriter, r *http.Request) {
+	app.userListsResponse(w, r, app.models.Lists.GetAllFollowed)
+}
+
+// userListsResponse pages through the lists that getAll finds for the current user.
+func (app *application) userListsResponse(w http.ResponseWriter, r *http.Request, getAll func(int64, int, model.Filters) ([]*model.List, model.Metadata, error)) {
+	var filters model.Filters
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	filters.Page = app.readInt(qs, "page", 1, v)
+	filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	filters.Sort = app.readString(qs, "sort", "-updated_at")
+	filters.SortSafelist = listSortSafelist
+
+	if model.ValidateFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	lists, metadata, err := getAll(user.ID, user.MaturityLevel, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"lists": lists, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createListHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Visibility  string `json:"visibility"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	list := &model.List{
+		UserID:      user.ID,
+		OwnerName:   user.Name,
+		Title:       input.Title,
+		Description: input.Description,
+		Visibility:  input.Visibility,
+	}
+	if list.Visibility == "" {
+		list.Visibility = model.VisibilityPrivate
+	}
+
+	v := validator.New()
+	if model.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Insert(list)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", app.etag(list.Version))
+	err = app.writeJSON(w, http.StatusCreated, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getListHandler shows a list with its items to anyone allowed to see it.
+func (app *application) getListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := app.models.Lists.GetItems(list.ID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	list.Items = items
+
+	w.Header().Set("ETag", app.etag(list.Version))
+	err = app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) patchListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readOwnList(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.ifMatch(r, list.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Title != nil {
+		list.Title = *input.Title
+	}
+	if input.Description != nil {
+		list.Description = *input.Description
+	}
+	if input.Visibility != nil {
+		list.Visibility = *input.Visibility
+	}
+
+	v := validator.New()
+	if model.ValidateList(v, list); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Update(list)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", app.etag(list.Version))
+	err = app.writeJSON(w, http.StatusOK, envelope{"list": list}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readOwnList(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Lists.Delete(list.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "list successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) addListItemHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readOwnList(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		DoramaID int    `json:"dorama_id"`
+		Note     string `json:"note"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(input.DoramaID > 0, "dorama_id", "must be provided")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	dorama, err := app.models.Doramas.Get(input.DoramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			v.AddError("dorama_id", "must be an existing dorama")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	item := &model.ListItem{
+		DoramaID:           dorama.DoramaId,
+		Title:              dorama.Title,
+		ReleaseYear:        dorama.ReleaseYear,
+		PosterThumbnailURL: dorama.PosterThumbnailURL,
+		Note:               input.Note,
+	}
+
+	if model.ValidateListItem(v, item); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.AddItem(list.ID, item)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrDuplicateListItem):
+			v.AddError("dorama_id", "this dorama is already on the list")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"item": item}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeListItemHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readOwnList(w, r)
+	if !ok {
+		return
+	}
+
+	doramaID, err := app.readIDParam(r, "dorama_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Lists.RemoveItem(list.ID, doramaID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "dorama successfully removed from list"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reorderListItemsHandler puts the items of a list in a new order. The request names
+// every dorama on the list that the owner can see, in the order they should appear.
+func (app *application) reorderListItemsHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readOwnList(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		DoramaIDs []int64 `json:"dorama_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(validator.Unique(input.DoramaIDs), "dorama_ids", "must not contain duplicate values")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Lists.Reorder(list.ID, input.DoramaIDs, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInvalidListOrder):
+			v.AddError("dorama_ids", "must contain every visible dorama on the list exactly once")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	items, err := app.models.Lists.GetItems(list.ID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"items": items}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) followListHandler(w http.ResponseWriter, r *http.Request) {
+	list, ok := app.readList(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Lists.Follow(list.ID, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "list successfully followed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) unfollowListHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["list_id"], 10, 64)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// Unfollowing doesn't need the list to be visible any more, so that users can drop
+	// lists that have been made private.
+	err = app.models.Lists.Unfollow(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "list successfully unfollowed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readList looks up the list named by the request path. Private lists of other users
+// are reported as not found. If the list can't be shown, the error response has
+// already been sent and ok is false.
+func (app *application) readList(w http.ResponseWriter, r *http.Request) (list *model.List, ok bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["list_id"], 10, 64)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	list, err = app.models.Lists.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !list.VisibleTo(app.contextGetUser(r)) {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	return list, true
+}
+
+// readOwnList is like readList, but only lets the owner of the list through, since
+// nobody else may change it.
+func (app *application) readOwnList(w http.ResponseWriter, r *http.Request) (list *model.List, ok bool) {
+	list, ok = app.readList(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if list.UserID != app.contextGetUser(r).ID {
+		app.notPermittedResponse(w, r)
+		return nil, false
+	}
+
+	return list, true
+}
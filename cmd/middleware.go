@@ -64,8 +64,16 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// Keep the session list up to date. This is only bookkeeping, so a failure is
+		// logged rather than failing the request.
+		err = app.models.Tokens.Touch(token)
+		if err != nil {
+			app.logError(r, err)
+		}
+
 		// Call the contextSetUser healer to add the user information to the request context.
 		r = app.contextSetUser(r, user)
+		r = app.contextSetToken(r, token)
 
 		// Call next handler in chain
 		next.ServeHTTP(w, r)
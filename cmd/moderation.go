@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func (app *application) reportReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review, ok := app.readReview(w, r)
+	if !ok {
+		return
+	}
+
+	app.createReport(w, r, model.TargetReview, review.ID)
+}
+
+func (app *application) reportCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.readComment(w, r)
+	if !ok {
+		return
+	}
+
+	app.createReport(w, r, model.TargetComment, comment.ID)
+}
+
+// createReport files the current user's report about a review or comment for the
+// moderators to look at.
+func (app *application) createReport(w http.ResponseWriter, r *http.Request, targetType string, targetID int64) {
+	var input struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	report := &model.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		UserID:     app.contextGetUser(r).ID,
+		Reason:     input.Reason,
+		Details:    input.Details,
+	}
+
+	v := validator.New()
+	if model.ValidateReport(v, report); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Moderation.Report(report)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrDuplicateReport):
+			v.AddError(targetType, "you have already reported this "+targetType)
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getModerationQueueHandler lists the reviews and comments with open reports, most
+// reported first.
+func (app *application) getModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TargetType string
+		model.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.TargetType = app.readString(qs, "target_type", "")
+	v.Check(input.TargetType == "" || validator.In(input.TargetType, model.ModerationTargets...), "target_type", "must be review or comment")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-report_count")
+	input.Filters.SortSafelist = []string{"report_count", "first_reported_at", "last_reported_at", "-report_count", "-first_reported_at", "-last_reported_at"}
+
+	if model.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, metadata, err := app.models.Moderation.GetQueue(input.TargetType, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"queue": items, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getModerationActionsHandler shows the audit trail of moderator decisions, newest
+// first.
+func (app *application) getModerationActionsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TargetType string
+		TargetID   int
+		model.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.TargetType = app.readString(qs, "target_type", "")
+	v.Check(input.TargetType == "" || validator.In(input.TargetType, model.ModerationTargets...), "target_type", "must be review or comment")
+	input.TargetID = app.readInt(qs, "target_id", 0, v)
+	v.Check(input.TargetID >= 0, "target_id", "must not be negative")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"created_at", "-created_at"}
+
+	if model.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	actions, metadata, err := app.models.Moderation.GetActions(input.TargetType, int64(input.TargetID), input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"actions": actions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createModerationActionHandler hides or restores a review or comment, or dismisses the
+// reports about it, and records the decision.
+func (app *application) createModerationActionHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TargetType string `json:"target_type"`
+		TargetID   int64  `json:"target_id"`
+		Action     string `json:"action"`
+		Reason     string `json:"reason"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	moderator := app.contextGetUser(r)
+	action := &model.ModerationAction{
+		TargetType:    input.TargetType,
+		TargetID:      input.TargetID,
+		ModeratorID:   moderator.ID,
+		ModeratorName: moderator.Name,
+		Action:        input.Action,
+		Reason:        input.Reason,
+	}
+
+	v := validator.New()
+	if model.ValidateModerationAction(v, action); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Moderation.Moderate(action)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			v.AddError("target_id", "must be an existing "+action.TargetType)
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrModerationNoOp) && action.Action == model.ModerationHide:
+			v.AddError("action", "this "+action.TargetType+" is already hidden")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrModerationNoOp):
+			v.AddError("action", "this "+action.TargetType+" is not hidden")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"action": action}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
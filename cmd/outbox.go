@@ -0,0 +1,102 @@
+package main
+
+import (
+	"time"
+
+	"github.com/makooster/MCA/pkg/model"
+)
+
+// Settings for delivering email from the outbox. A failed email is retried after
+// mailRetryBase, then twice as long after each further failure up to mailRetryMax,
+// and is given up on after mailMaxAttempts tries, about a day after it was queued.
+const (
+	mailBatchSize   = 10
+	mailLease       = 5 * time.Minute
+	mailRetryBase   = 30 * time.Second
+	mailRetryMax    = 4 * time.Hour
+	mailMaxAttempts = 15
+)
+
+// deliverMail sends queued email every interval until the server shuts down.
+func (app *application) deliverMail(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.flushOutbox()
+
+		select {
+		case <-app.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flushOutbox sends every email that is due, a batch at a time. It stops between two
+// emails if the server is shutting down; what's left is sent on the next start.
+func (app *application) flushOutbox() {
+	for {
+		emails, err := app.models.Outbox.Claim(mailBatchSize, mailLease)
+		if err != nil {
+			app.logger.Println(err)
+			return
+		}
+
+		for _, email := range emails {
+			select {
+			case <-app.quit:
+				return
+			default:
+			}
+
+			app.deliverEmail(email)
+		}
+
+		if len(emails) < mailBatchSize {
+			return
+		}
+	}
+}
+
+// deliverEmail makes one attempt at sending an email and records the outcome.
+func (app *application) deliverEmail(email *model.OutboxEmail) {
+	sendErr := app.mailer.Send(email.Recipient, email.Template, email.Data)
+	if sendErr == nil {
+		err := app.models.Outbox.MarkSent(email)
+		if err != nil {
+			app.logger.Println(err)
+		}
+		return
+	}
+
+	var retryAt time.Time
+	if email.Attempts+1 < mailMaxAttempts {
+		retryAt = time.Now().Add(mailRetryDelay(email.Attempts + 1))
+	}
+
+	err := app.models.Outbox.MarkFailed(email, sendErr, retryAt)
+	if err != nil {
+		app.logger.Println(err)
+		return
+	}
+
+	if email.Status == model.EmailDead {
+		app.logger.Printf("giving up on email %d (%s to %s) after %d attempts: %v", email.ID, email.Template, email.Recipient, email.Attempts, sendErr)
+		return
+	}
+	app.logger.Printf("sending email %d (%s to %s) failed, retrying at %s: %v", email.ID, email.Template, email.Recipient, email.NextAttemptAt.Format(time.RFC3339), sendErr)
+}
+
+// mailRetryDelay returns how long to wait before retrying an email that has failed the
+// given number of times.
+func mailRetryDelay(attempts int) time.Duration {
+	delay := mailRetryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= mailRetryMax {
+			return mailRetryMax
+		}
+	}
+	return delay
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMailRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{9, 128 * time.Minute},
+		{10, mailRetryMax},
+		{mailMaxAttempts, mailRetryMax},
+		{1000, mailRetryMax},
+	}
+
+	for _, tt := range tests {
+		if got := mailRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("mailRetryDelay(%d) = %s; want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// TestMailRetryWindow checks that an email is given up on about a day after it was
+// queued, as the README promises.
+func TestMailRetryWindow(t *testing.T) {
+	var total time.Duration
+	for attempts := 1; attempts < mailMaxAttempts; attempts++ {
+		total += mailRetryDelay(attempts)
+	}
+
+	if total < 20*time.Hour || total > 28*time.Hour {
+		t.Errorf("got %s between the first and last attempt; want about a day", total)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func (app *application) getDoramaRevisionListHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readRevisionDoramaID(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		model.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	// Show the most recent changes first unless the client asks otherwise.
+	input.Filters.Sort = app.readString(qs, "sort", "-revision")
+	input.Filters.SortSafelist = []string{"revision", "created_at", "-revision", "-created_at"}
+
+	if model.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	revisions, metadata, err := app.models.Revisions.GetAllForEntity("dorama", id, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getDoramaRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readRevisionDoramaID(w, r)
+	if !ok {
+		return
+	}
+
+	rev, err := app.readIDParam(r, "rev")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	revision, err := app.models.Revisions.Get("dorama", id, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getDoramaRevisionDiffHandler compares the dorama as it stood at two revisions, given
+// by the from and to query string values, and lists the fields that changed.
+func (app *application) getDoramaRevisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.readRevisionDoramaID(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	fromRev := app.readInt(qs, "from", 0, v)
+	toRev := app.readInt(qs, "to", 0, v)
+	v.Check(fromRev > 0, "from", "must be a revision number")
+	v.Check(toRev > 0, "to", "must be a revision number")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	from, err := app.models.Revisions.Get("dorama", id, fromRev)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	to, err := app.models.Revisions.Get("dorama", id, toRev)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	changes, err := model.DiffRevisions(from, to)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"from": fromRev, "to": toRev, "changes": changes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertDoramaRevisionHandler puts the dorama's fields back the way they were at the
+// given revision. The revert is saved as a normal update, so it shows up in the history
+// and can itself be reverted.
+func (app *application) revertDoramaRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	rev, err := app.readIDParam(r, "rev")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	dorama, err := app.models.Doramas.Get(id, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.ifMatch(r, dorama.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	revision, err := app.models.Revisions.Get("dorama", id, rev)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var previous model.DoramaSnapshot
+	err = json.Unmarshal(revision.State(), &previous)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	dorama.Title = previous.Title
+	dorama.Description = previous.Description
+	dorama.ReleaseYear = previous.ReleaseYear
+	dorama.Duration = previous.Duration
+	// Revisions recorded before doramas had an airing status don't carry one, so keep
+	// the current status and dates for those.
+	if previous.Status != "" {
+		dorama.Status = previous.Status
+		dorama.StartDate = previous.StartDate
+		dorama.EndDate = previous.EndDate
+	}
+	dorama.GenreIDs = previous.GenreIDs
+	dorama.CompanyIDs = previous.CompanyIDs
+	dorama.CountryCodes = previous.CountryCodes
+	// Likewise, revisions from before content ratings have no list of warnings at all.
+	if previous.ContentWarnings != nil {
+		dorama.RatingSystem = previous.RatingSystem
+		dorama.ContentRating = previous.ContentRating
+		dorama.ContentWarnings = previous.ContentWarnings
+	}
+
+	// The old revision may refer to associations that have since been removed, so the
+	// reverted record goes through the same checks as any other update.
+	v := validator.New()
+	if model.ValidateDorama(v, dorama); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Revisions from before the cast was recorded leave the current cast and crew alone.
+	err = app.models.Doramas.Revert(dorama, previous.Credits, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrUnknownActor):
+			v.AddError("credits", "the revision refers to people who no longer exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrUnknownGenre):
+			v.AddError("genre_ids", "the revision refers to genres that no longer exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrUnknownCompany):
+			v.AddError("company_ids", "the revision refers to companies that no longer exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrUnknownCountry):
+			v.AddError("country_codes", "the revision refers to countries that no longer exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, model.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", app.etag(dorama.Version))
+	err = app.writeJSON(w, http.StatusOK, envelope{"dorama": dorama}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readRevisionDoramaID reads the ID of the dorama whose history the request is about,
+// and makes sure the user is allowed to see it. History stays available for trashed
+// doramas, so unlike Doramas.Get this doesn't require the dorama to be live. If the
+// check fails, the error response has already been sent and ok is false.
+func (app *application) readRevisionDoramaID(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return 0, false
+	}
+
+	minAge, err := app.models.Doramas.GetMinAge(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return 0, false
+	}
+
+	if minAge > app.contextGetUser(r).MaturityLevel {
+		app.notFoundResponse(w, r)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/makooster/MCA/pkg/model"
+)
+
+func TestRevisionsRespectMaturityLevel(t *testing.T) {
+	app := newTestApplication(t, true)
+
+	adult := &model.Dorama{
+		Title:         fmt.Sprintf("Test dorama %d", time.Now().UnixNano()),
+		Status:        model.StatusAnnounced,
+		RatingSystem:  "KMRB",
+		ContentRating: "19",
+	}
+	err := app.models.Doramas.Insert(adult, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.models.Doramas.DB.Exec(`DELETE FROM doramas WHERE dorama_id = $1`, adult.DoramaId)
+		app.models.Doramas.DB.Exec(`DELETE FROM revisions WHERE entity_type = 'dorama' AND entity_id = $1`, adult.DoramaId)
+	})
+
+	id := strconv.Itoa(adult.DoramaId)
+	rev := strconv.Itoa(adult.Version)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		vars    map[string]string
+	}{
+		{"list", app.getDoramaRevisionListHandler, "/app/doramas/" + id + "/revisions", map[string]string{"id": id}},
+		{"show", app.getDoramaRevisionHandler, "/app/doramas/" + id + "/revisions/" + rev, map[string]string{"id": id, "rev": rev}},
+		{"diff", app.getDoramaRevisionDiffHandler, "/app/doramas/" + id + "/revisions/diff?from=" + rev + "&to=" + rev, map[string]string{"id": id}},
+		{"franchise", app.getDoramaFranchiseHandler, "/app/doramas/" + id + "/franchise", map[string]string{"id": id}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := app.serveAs(&model.User{MaturityLevel: 15}, tt.handler, http.MethodGet, tt.target, tt.vars)
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("restricted user: got status %d; want %d", rr.Code, http.StatusNotFound)
+			}
+
+			rr = app.serveAs(&model.User{MaturityLevel: model.MaturityAdult}, tt.handler, http.MethodGet, tt.target, tt.vars)
+			if rr.Code != http.StatusOK {
+				t.Errorf("adult user: got status %d; want %d", rr.Code, http.StatusOK)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"github.com/gorilla/mux"
+	"github.com/makooster/MCA/pkg/storage"
 )
 
 // "github.com/julienschmidt/httprouter"
@@ -30,23 +31,153 @@ func (app *application) routes()  http.Handler {
 	router.HandleFunc("/app/doramas", app.requirePermission("movies:write", app.createDoramaHandler)).Methods("POST")
 	router.HandleFunc("/app/doramas/{id:[0-9]+}", app.requirePermission("movies:read", app.getDoramaHandler)).Methods("GET")
 	router.HandleFunc("/app/doramas/{id:[0-9]+}", app.requirePermission("movies:write", app.updateDoramaHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}", app.requirePermission("movies:write", app.patchDoramaHandler)).Methods("PATCH")
 	router.HandleFunc("/app/doramas/{id:[0-9]+}", app.requirePermission("movies:write", app.deleteDoramaHandler)).Methods("DELETE")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/revisions", app.requirePermission("movies:read", app.getDoramaRevisionListHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/revisions/diff", app.requirePermission("movies:read", app.getDoramaRevisionDiffHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/revisions/{rev:[0-9]+}", app.requirePermission("movies:read", app.getDoramaRevisionHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/revisions/{rev:[0-9]+}/revert", app.requirePermission("movies:write", app.revertDoramaRevisionHandler)).Methods("POST")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/translations", app.requirePermission("movies:read", app.getDoramaTranslationsHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/translations/{lang}", app.requirePermission("movies:write", app.setDoramaTranslationHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/translations/{lang}", app.requirePermission("movies:write", app.deleteDoramaTranslationHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/aliases", app.requirePermission("movies:read", app.getAliasListHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/aliases", app.requirePermission("movies:write", app.createAliasHandler)).Methods("POST")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/aliases/{alias_id:[0-9]+}", app.requirePermission("movies:write", app.updateAliasHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/aliases/{alias_id:[0-9]+}", app.requirePermission("movies:write", app.deleteAliasHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/broadcasts", app.requirePermission("movies:read", app.getBroadcastSlotListHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/broadcasts", app.requirePermission("movies:write", app.createBroadcastSlotHandler)).Methods("POST")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/broadcasts/{slot_id:[0-9]+}", app.requirePermission("movies:write", app.updateBroadcastSlotHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/broadcasts/{slot_id:[0-9]+}", app.requirePermission("movies:write", app.deleteBroadcastSlotHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/poster", app.requirePermission("movies:write", app.uploadDoramaPosterHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/poster", app.requirePermission("movies:write", app.deleteDoramaPosterHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/related", app.requirePermission("movies:read", app.getDoramaRelationsHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/related/{related_id:[0-9]+}", app.requirePermission("movies:write", app.setDoramaRelationHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/related/{related_id:[0-9]+}", app.requirePermission("movies:write", app.removeDoramaRelationHandler)).Methods("DELETE")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/franchise", app.requirePermission("movies:read", app.getDoramaFranchiseHandler)).Methods("GET")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews", app.requirePermission("movies:read", app.getReviewListHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews", app.requirePermission("reviews:write", app.createReviewHandler)).Methods("POST")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}", app.requirePermission("movies:read", app.getReviewHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}", app.requirePermission("reviews:write", app.patchReviewHandler)).Methods("PATCH")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}", app.requirePermission("reviews:write", app.deleteReviewHandler)).Methods("DELETE")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}/like", app.requirePermission("reviews:write", app.likeReviewHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}/like", app.requirePermission("reviews:write", app.unlikeReviewHandler)).Methods("DELETE")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}/reports", app.requirePermission("reviews:write", app.reportReviewHandler)).Methods("POST")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}/comments", app.requirePermission("movies:read", app.getCommentListHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}/comments", app.requirePermission("reviews:write", app.createCommentHandler)).Methods("POST")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}/comments/{comment_id:[0-9]+}", app.requirePermission("reviews:write", app.patchCommentHandler)).Methods("PATCH")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}/comments/{comment_id:[0-9]+}", app.requirePermission("reviews:write", app.deleteCommentHandler)).Methods("DELETE")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/reviews/{review_id:[0-9]+}/comments/{comment_id:[0-9]+}/reports", app.requirePermission("reviews:write", app.reportCommentHandler)).Methods("POST")
+
+	router.HandleFunc("/app/moderation/queue", app.requirePermission("content:moderate", app.getModerationQueueHandler)).Methods("GET")
+	router.HandleFunc("/app/moderation/actions", app.requirePermission("content:moderate", app.getModerationActionsHandler)).Methods("GET")
+	router.HandleFunc("/app/moderation/actions", app.requirePermission("content:moderate", app.createModerationActionHandler)).Methods("POST")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/genres", app.requirePermission("movies:read", app.getDoramaGenresHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/cast", app.requirePermission("movies:read", app.getDoramaCastHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/cast/{actor_id:[0-9]+}", app.requirePermission("movies:write", app.setDoramaCastMemberHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/cast/{actor_id:[0-9]+}", app.requirePermission("movies:write", app.removeDoramaCastMemberHandler)).Methods("DELETE")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/credits", app.requirePermission("movies:read", app.getDoramaCreditsHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/credits/{actor_id:[0-9]+}/{role:[a-z]+}", app.requirePermission("movies:write", app.setDoramaCreditHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/credits/{actor_id:[0-9]+}/{role:[a-z]+}", app.requirePermission("movies:write", app.removeDoramaCreditHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/seasons", app.requirePermission("movies:read", app.getSeasonListHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/seasons", app.requirePermission("movies:write", app.createSeasonHandler)).Methods("POST")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/seasons/{season:[0-9]+}", app.requirePermission("movies:read", app.getSeasonHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/seasons/{season:[0-9]+}", app.requirePermission("movies:write", app.updateSeasonHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/seasons/{season:[0-9]+}", app.requirePermission("movies:write", app.deleteSeasonHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/episodes", app.requirePermission("movies:read", app.getEpisodeListHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/episodes", app.requirePermission("movies:write", app.createEpisodeHandler)).Methods("POST")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/episodes/{episode_id:[0-9]+}", app.requirePermission("movies:read", app.getEpisodeHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/episodes/{episode_id:[0-9]+}", app.requirePermission("movies:write", app.updateEpisodeHandler)).Methods("PUT")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/episodes/{episode_id:[0-9]+}", app.requirePermission("movies:write", app.deleteEpisodeHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/schedule", app.requirePermission("movies:read", app.getScheduleHandler)).Methods("GET")
 
 	router.HandleFunc("/app/actors", app.requirePermission("movies:read", app.getActorListHandler)).Methods("GET")
 	router.HandleFunc("/app/actors", app.requirePermission("movies:write", app.createActorHandler)).Methods("POST")
 	router.HandleFunc("/app/actors/{id:[0-9]+}", app.requirePermission("movies:read", app.getActorHandler)).Methods("GET")
 	router.HandleFunc("/app/actors/{id:[0-9]+}", app.requirePermission("movies:write", app.updateActorHandler)).Methods("PUT")
+	router.HandleFunc("/app/actors/{id:[0-9]+}", app.requirePermission("movies:write", app.patchActorHandler)).Methods("PATCH")
 	router.HandleFunc("/app/actors/{id:[0-9]+}", app.requirePermission("movies:write", app.deleteActorHandler)).Methods("DELETE")
+	router.HandleFunc("/app/actors/{id:[0-9]+}/filmography", app.requirePermission("movies:read", app.getActorFilmographyHandler)).Methods("GET")
+	router.HandleFunc("/app/actors/{id:[0-9]+}/photo", app.requirePermission("movies:write", app.uploadActorPhotoHandler)).Methods("PUT")
+	router.HandleFunc("/app/actors/{id:[0-9]+}/photo", app.requirePermission("movies:write", app.deleteActorPhotoHandler)).Methods("DELETE")
 
 	router.HandleFunc("/app/genres", app.requirePermission("movies:read", app.getGenresListHandler)).Methods("GET")
 	router.HandleFunc("/app/genres", app.requirePermission("movies:write", app.createGenreHandler)).Methods("POST")
 	router.HandleFunc("/app/genres/{id:[0-9]+}", app.requirePermission("movies:read", app.getGenreHandler)).Methods("GET")
 	router.HandleFunc("/app/genres/{id:[0-9]+}", app.requirePermission("movies:write", app.updateGenreHandler)).Methods("PUT")
+	router.HandleFunc("/app/genres/{id:[0-9]+}", app.requirePermission("movies:write", app.patchGenreHandler)).Methods("PATCH")
 	router.HandleFunc("/app/genres/{id:[0-9]+}", app.requirePermission("movies:write", app.deleteGenreHandler)).Methods("DELETE")
 
+	router.HandleFunc("/app/genres/{id:[0-9]+}/translations", app.requirePermission("movies:read", app.getGenreTranslationsHandler)).Methods("GET")
+	router.HandleFunc("/app/genres/{id:[0-9]+}/translations/{lang}", app.requirePermission("movies:write", app.setGenreTranslationHandler)).Methods("PUT")
+	router.HandleFunc("/app/genres/{id:[0-9]+}/translations/{lang}", app.requirePermission("movies:write", app.deleteGenreTranslationHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/companies", app.requirePermission("movies:read", app.getCompanyListHandler)).Methods("GET")
+	router.HandleFunc("/app/companies", app.requirePermission("movies:write", app.createCompanyHandler)).Methods("POST")
+	router.HandleFunc("/app/companies/{id:[0-9]+}", app.requirePermission("movies:read", app.getCompanyHandler)).Methods("GET")
+	router.HandleFunc("/app/companies/{id:[0-9]+}", app.requirePermission("movies:write", app.updateCompanyHandler)).Methods("PUT")
+	router.HandleFunc("/app/companies/{id:[0-9]+}", app.requirePermission("movies:write", app.patchCompanyHandler)).Methods("PATCH")
+	router.HandleFunc("/app/companies/{id:[0-9]+}", app.requirePermission("movies:write", app.deleteCompanyHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/countries", app.requirePermission("movies:read", app.getCountryListHandler)).Methods("GET")
+	router.HandleFunc("/app/countries", app.requirePermission("movies:write", app.createCountryHandler)).Methods("POST")
+	router.HandleFunc("/app/countries/{id:[0-9]+}", app.requirePermission("movies:read", app.getCountryHandler)).Methods("GET")
+	router.HandleFunc("/app/countries/{id:[0-9]+}", app.requirePermission("movies:write", app.updateCountryHandler)).Methods("PUT")
+	router.HandleFunc("/app/countries/{id:[0-9]+}", app.requirePermission("movies:write", app.patchCountryHandler)).Methods("PATCH")
+	router.HandleFunc("/app/countries/{id:[0-9]+}", app.requirePermission("movies:write", app.deleteCountryHandler)).Methods("DELETE")
+
+	router.HandleFunc("/app/trash", app.requirePermission("movies:admin", app.getTrashHandler)).Methods("GET")
+	router.HandleFunc("/app/doramas/{id:[0-9]+}/restore", app.requirePermission("movies:admin", app.restoreDoramaHandler)).Methods("POST")
+	router.HandleFunc("/app/actors/{id:[0-9]+}/restore", app.requirePermission("movies:admin", app.restoreActorHandler)).Methods("POST")
+	router.HandleFunc("/app/genres/{id:[0-9]+}/restore", app.requirePermission("movies:admin", app.restoreGenreHandler)).Methods("POST")
+
+	// Uploaded images are public. When they are kept on the local file system we serve
+	// them ourselves, under the path of the -storage-url they are linked from; other
+	// storage backends hand out their own URLs.
+	if fs, ok := app.storage.(*storage.FileSystem); ok {
+		prefix := storageURLPath(fs.BaseURL) + "/"
+		router.PathPrefix(prefix).Handler(http.StripPrefix(prefix, app.serveImages(fs.Root))).Methods("GET")
+	}
+
 	router.HandleFunc("/app/users", app.registerUserHandler).Methods("POST")
 	router.HandleFunc("/app/users/activated", app.activateUserHandler).Methods("PUT")
+	router.HandleFunc("/app/users/email", app.confirmEmailChangeHandler).Methods("PUT")
+	router.HandleFunc("/app/users/password", app.updateUserPasswordHandler).Methods("PUT")
+	router.HandleFunc("/app/users/me", app.requireAuthenticatedUser(app.showCurrentUserHandler)).Methods("GET")
+	router.HandleFunc("/app/users/me", app.requireAuthenticatedUser(app.updateCurrentUserHandler)).Methods("PATCH")
+	router.HandleFunc("/app/users/me/sessions", app.requireAuthenticatedUser(app.getSessionListHandler)).Methods("GET")
+	router.HandleFunc("/app/users/me/sessions/{session_id:[0-9]+}", app.requireAuthenticatedUser(app.deleteSessionHandler)).Methods("DELETE")
+	router.HandleFunc("/app/users/me/watchlist", app.requireActivatedUser(app.getWatchlistHandler)).Methods("GET")
+	router.HandleFunc("/app/users/me/watchlist/{dorama_id:[0-9]+}", app.requireActivatedUser(app.getWatchlistEntryHandler)).Methods("GET")
+	router.HandleFunc("/app/users/me/watchlist/{dorama_id:[0-9]+}", app.requireActivatedUser(app.setWatchlistEntryHandler)).Methods("PUT")
+	router.HandleFunc("/app/users/me/watchlist/{dorama_id:[0-9]+}", app.requireActivatedUser(app.patchWatchlistEntryHandler)).Methods("PATCH")
+	router.HandleFunc("/app/users/me/watchlist/{dorama_id:[0-9]+}", app.requireActivatedUser(app.deleteWatchlistEntryHandler)).Methods("DELETE")
+	router.HandleFunc("/app/users/me/lists", app.requireActivatedUser(app.getMyListsHandler)).Methods("GET")
+	router.HandleFunc("/app/users/me/lists/followed", app.requireActivatedUser(app.getFollowedListsHandler)).Methods("GET")
+
+	router.HandleFunc("/app/lists", app.requireActivatedUser(app.discoverListsHandler)).Methods("GET")
+	router.HandleFunc("/app/lists", app.requireActivatedUser(app.createListHandler)).Methods("POST")
+	router.HandleFunc("/app/lists/{list_id:[0-9]+}", app.requireActivatedUser(app.getListHandler)).Methods("GET")
+	router.HandleFunc("/app/lists/{list_id:[0-9]+}", app.requireActivatedUser(app.patchListHandler)).Methods("PATCH")
+	router.HandleFunc("/app/lists/{list_id:[0-9]+}", app.requireActivatedUser(app.deleteListHandler)).Methods("DELETE")
+	router.HandleFunc("/app/lists/{list_id:[0-9]+}/items", app.requireActivatedUser(app.addListItemHandler)).Methods("POST")
+	router.HandleFunc("/app/lists/{list_id:[0-9]+}/items/order", app.requireActivatedUser(app.reorderListItemsHandler)).Methods("PUT")
+	router.HandleFunc("/app/lists/{list_id:[0-9]+}/items/{dorama_id:[0-9]+}", app.requireActivatedUser(app.removeListItemHandler)).Methods("DELETE")
+	router.HandleFunc("/app/lists/{list_id:[0-9]+}/follow", app.requireActivatedUser(app.followListHandler)).Methods("PUT")
+	router.HandleFunc("/app/lists/{list_id:[0-9]+}/follow", app.requireActivatedUser(app.unfollowListHandler)).Methods("DELETE")
 	router.HandleFunc("/app/tokens/login", app.createAuthenticationTokenHandler).Methods("POST")
+	router.HandleFunc("/app/tokens/password-reset", app.createPasswordResetTokenHandler).Methods("POST")
+	router.HandleFunc("/app/tokens/activation", app.createActivationTokenHandler).Methods("POST")
+	router.HandleFunc("/app/tokens/current", app.requireAuthenticatedUser(app.deleteCurrentTokenHandler)).Methods("DELETE")
 
 	// return router
 	return app.authenticate(router)
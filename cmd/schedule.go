@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// getScheduleHandler lists the individual airings of every airing or announced dorama
+// between the from and to dates. Both default to a week starting today, and the range
+// is capped so that a single request can't expand into years of airings.
+func (app *application) getScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		From string
+		To   string
+		model.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	today := time.Now().Format("2006-01-02")
+	input.From = app.readString(qs, "from", today)
+	v.Check(validator.Date(input.From), "from", "must be a date in YYYY-MM-DD format")
+
+	if v.Valid() {
+		from, _ := time.Parse("2006-01-02", input.From)
+		input.To = app.readString(qs, "to", from.AddDate(0, 0, 6).Format("2006-01-02"))
+		v.Check(validator.Date(input.To), "to", "must be a date in YYYY-MM-DD format")
+
+		if v.Valid() {
+			to, _ := time.Parse("2006-01-02", input.To)
+			v.Check(!to.Before(from), "to", "must not be before from")
+			v.Check(to.Sub(from) <= 90*24*time.Hour, "to", "must be at most 90 days after from")
+		}
+	}
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "air_date")
+	input.Filters.SortSafelist = []string{"air_date", "-air_date"}
+
+	if model.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	schedule, metadata, err := app.models.Schedule.GetSchedule(input.From, input.To, app.contextGetUser(r).MaturityLevel, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"schedule": schedule, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getBroadcastSlotListHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	slots, err := app.models.Schedule.GetAllForDorama(doramaID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"broadcast_slots": slots}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createBroadcastSlotHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Weekday  int    `json:"weekday"`
+		AirTime  string `json:"air_time"`
+		Timezone string `json:"timezone"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	slot := &model.BroadcastSlot{
+		DoramaID: doramaID,
+		Weekday:  input.Weekday,
+		AirTime:  input.AirTime,
+		Timezone: input.Timezone,
+	}
+	if slot.Timezone == "" {
+		slot.Timezone = "Asia/Seoul"
+	}
+
+	v := validator.New()
+	if model.ValidateBroadcastSlot(v, slot); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Schedule.Insert(slot)
+	if err != nil {
+		app.broadcastSlotWriteErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"broadcast_slot": slot}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateBroadcastSlotHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r, "slot_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	slot, err := app.models.Schedule.Get(doramaID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Weekday  int    `json:"weekday"`
+		AirTime  string `json:"air_time"`
+		Timezone string `json:"timezone"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	slot.Weekday = input.Weekday
+	slot.AirTime = input.AirTime
+	slot.Timezone = input.Timezone
+	if slot.Timezone == "" {
+		slot.Timezone = "Asia/Seoul"
+	}
+
+	v := validator.New()
+	if model.ValidateBroadcastSlot(v, slot); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Schedule.Update(slot)
+	if err != nil {
+		app.broadcastSlotWriteErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"broadcast_slot": slot}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteBroadcastSlotHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r, "slot_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Schedule.Delete(doramaID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "broadcast slot successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// broadcastSlotWriteErrorResponse reports the errors that ScheduleModel.Insert and
+// Update can return.
+func (app *application) broadcastSlotWriteErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, model.ErrDuplicateBroadcastSlot):
+		v.AddError("air_time", "this dorama already airs at this time on this weekday")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, model.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/makooster/MCA/pkg/model"
+)
+
+func TestSeasonsAndEpisodesRespectMaturityLevel(t *testing.T) {
+	app := newTestApplication(t, true)
+
+	adult := &model.Dorama{
+		Title:         fmt.Sprintf("Test dorama %d", time.Now().UnixNano()),
+		Status:        model.StatusAnnounced,
+		RatingSystem:  "KMRB",
+		ContentRating: "19",
+	}
+	err := app.models.Doramas.Insert(adult, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.models.Doramas.DB.Exec(`DELETE FROM doramas WHERE dorama_id = $1`, adult.DoramaId)
+		app.models.Doramas.DB.Exec(`DELETE FROM revisions WHERE entity_type = 'dorama' AND entity_id = $1`, adult.DoramaId)
+	})
+
+	err = app.models.Seasons.Insert(&model.Season{DoramaID: adult.DoramaId, Number: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	episode := &model.Episode{DoramaID: adult.DoramaId, SeasonNumber: 1, Number: 1}
+	err = app.models.Episodes.Insert(episode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := strconv.Itoa(adult.DoramaId)
+	episodeID := strconv.Itoa(episode.ID)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		vars    map[string]string
+	}{
+		{"get season", app.getSeasonHandler, http.MethodGet, "/app/doramas/" + id + "/seasons/1", map[string]string{"id": id, "season": "1"}},
+		{"delete season", app.deleteSeasonHandler, http.MethodDelete, "/app/doramas/" + id + "/seasons/1", map[string]string{"id": id, "season": "1"}},
+		{"get episode", app.getEpisodeHandler, http.MethodGet, "/app/doramas/" + id + "/episodes/" + episodeID, map[string]string{"id": id, "episode_id": episodeID}},
+		{"delete episode", app.deleteEpisodeHandler, http.MethodDelete, "/app/doramas/" + id + "/episodes/" + episodeID, map[string]string{"id": id, "episode_id": episodeID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := app.serveAs(&model.User{MaturityLevel: 15}, tt.handler, tt.method, tt.target, tt.vars)
+			if rr.Code != http.StatusNotFound {
+				t.Errorf("restricted user: got status %d; want %d", rr.Code, http.StatusNotFound)
+			}
+		})
+	}
+
+	_, err = app.models.Episodes.Get(adult.DoramaId, episode.ID)
+	if err != nil {
+		t.Errorf("episode after restricted deletes: %v", err)
+	}
+
+	rr := app.serveAs(&model.User{MaturityLevel: model.MaturityAdult}, app.getEpisodeHandler, http.MethodGet, tests[2].target, tests[2].vars)
+	if rr.Code != http.StatusOK {
+		t.Errorf("adult user: got status %d; want %d", rr.Code, http.StatusOK)
+	}
+}
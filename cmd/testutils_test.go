@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/makooster/MCA/pkg/model"
+)
+
+// newTestApplication returns an application that discards its log output. Its models
+// are connected to the database named by MCA_TEST_DB_DSN when needsDB is set, and the
+// test is skipped if that variable isn't set; the database must be a disposable one
+// with every migration applied.
+func newTestApplication(t *testing.T, needsDB bool) *application {
+	t.Helper()
+
+	app := &application{
+		logger: log.New(io.Discard, "", 0),
+		quit:   make(chan struct{}),
+	}
+
+	if !needsDB {
+		return app
+	}
+
+	dsn := os.Getenv("MCA_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("MCA_TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.models = model.NewModels(db)
+	return app
+}
+
+// serveAs calls handler with a request made by user, with vars as the route variables,
+// and returns the recorded response.
+func (app *application) serveAs(user *model.User, handler http.HandlerFunc, method, target string, vars map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r = mux.SetURLVars(r, vars)
+	r = app.contextSetUser(r, user)
+
+	rr := httptest.NewRecorder()
+	handler(rr, r)
+	return rr
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// keyedLimiter allows each key at most limit requests in any window of the given
+// length. It is kept in memory, so every server instance counts on its own.
+type keyedLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	hits      map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newKeyedLimiter(limit int, window time.Duration) *keyedLimiter {
+	return &keyedLimiter{
+		limit:     limit,
+		window:    window,
+		hits:      make(map[string][]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow records a request for key and reports whether it is within the limit. If it
+// isn't, retryAfter is how long until the oldest request in the window expires.
+func (l *keyedLimiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-l.window)
+
+	// Forget keys that have been quiet for a whole window now and then, so that the
+	// map doesn't keep growing with addresses that are never seen again.
+	if now.Sub(l.lastSweep) > l.window {
+		for k, times := range l.hits {
+			if times[len(times)-1].Before(cutoff) {
+				delete(l.hits, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	times := l.hits[key]
+	for len(times) > 0 && times[0].Before(cutoff) {
+		times = times[1:]
+	}
+
+	if len(times) >= l.limit {
+		l.hits[key] = times
+		return false, times[0].Sub(cutoff)
+	}
+
+	l.hits[key] = append(times, now)
+	return true, 0
+}
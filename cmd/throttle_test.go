@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyedLimiterAllow(t *testing.T) {
+	const window = 100 * time.Millisecond
+	l := newKeyedLimiter(2, window)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a@example.com"); !ok {
+			t.Fatalf("request %d refused; want it allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := l.Allow("a@example.com")
+	if ok {
+		t.Fatal("request over the limit allowed")
+	}
+	if retryAfter <= 0 || retryAfter > window {
+		t.Errorf("got retry after %s; want it within the window of %s", retryAfter, window)
+	}
+
+	if ok, _ := l.Allow("b@example.com"); !ok {
+		t.Error("another key refused; want keys counted separately")
+	}
+
+	time.Sleep(window + 10*time.Millisecond)
+
+	if ok, _ := l.Allow("a@example.com"); !ok {
+		t.Error("request refused after the window passed")
+	}
+	if _, found := l.hits["b@example.com"]; found {
+		t.Error("quiet key kept after a whole window")
+	}
+}
@@ -2,7 +2,10 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"github.com/gorilla/mux"
 	"github.com/makooster/MCA/pkg/model"
 	"github.com/makooster/MCA/pkg/validator"
 )
@@ -52,8 +55,8 @@ var input struct {
 	return
 	}
 	// Otherwise, if the password is correct, we generate a new token with a 24-hour
-	// expiry time and the scope 'authentication'.
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, model.ScopeAuthentication)
+	// expiry time and the scope 'authentication', noting the client it was issued to.
+	token, err := app.models.Tokens.NewSession(user.ID, 24*time.Hour, r.UserAgent(), app.clientIP(r))
 	if err != nil {
 	app.serverErrorResponse(w, r, err)
 	return
@@ -65,4 +68,201 @@ var input struct {
 	app.serverErrorResponse(w, r, err)
 	}
 	}
-	
\ No newline at end of file
+	
+// createPasswordResetTokenHandler issues a short-lived token the user can trade for a
+// new password. The response is the same whether or not the address belongs to an
+// account, so that it can't be used to find out who is registered.
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if model.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	env := envelope{"message": "an email will be sent to you containing password reset instructions"}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	switch {
+	case errors.Is(err, model.ErrRecordNotFound):
+		err = app.writeJSON(w, http.StatusAccepted, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Only the latest reset token is any good.
+	err = app.models.Tokens.DeleteAllForUser(model.ScopePasswordReset, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.NewWithEmail(user.ID, 45*time.Minute, model.ScopePasswordReset, &model.OutboxEmail{
+		Recipient: user.Email,
+		Template:  "password_reset.tmpl",
+		Data:      map[string]interface{}{"name": user.Name},
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Handing the token to whoever asks would let anyone take over any account, so it
+	// is only shown when the server was started with -expose-tokens.
+	if app.config.exposeTokens {
+		env["password_reset_token"] = token
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createActivationTokenHandler mails a fresh activation token to a user whose earlier
+// one expired or got lost. Like the password reset endpoint it answers the same way
+// whether or not there is an account waiting for activation at the address, and each
+// address can only ask a few times an hour, whether it is registered or not.
+func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if model.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Email addresses are case-insensitive in the users table, so they are counted
+	// that way too.
+	if ok, retryAfter := app.activationLimiter.Allow(strings.ToLower(input.Email)); !ok {
+		app.rateLimitExceededResponse(w, r, retryAfter)
+		return
+	}
+
+	env := envelope{"message": "if an account for this address is waiting for activation, an email will be sent to you containing activation instructions"}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	switch {
+	case errors.Is(err, model.ErrRecordNotFound):
+		err = app.writeJSON(w, http.StatusAccepted, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if user.Activated {
+		err = app.writeJSON(w, http.StatusAccepted, env, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Only the new token should work from now on.
+	err = app.models.Tokens.DeleteAllForUser(model.ScopeActivation, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.NewWithEmail(user.ID, 3*24*time.Hour, model.ScopeActivation, &model.OutboxEmail{
+		Recipient: user.Email,
+		Template:  "token_activation.tmpl",
+		Data:      map[string]interface{}{"name": user.Name},
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if app.config.exposeTokens {
+		env["activation_token"] = token
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteCurrentTokenHandler signs the client out by revoking the token it authenticated
+// the request with.
+func (app *application) deleteCurrentTokenHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.models.Tokens.DeleteByPlaintext(model.ScopeAuthentication, app.contextGetToken(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "you have been signed out"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getSessionListHandler lists the places the user is signed in, so that they can spot
+// and revoke the ones they don't recognise.
+func (app *application) getSessionListHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, err := app.models.Tokens.GetSessionsForUser(app.contextGetUser(r).ID, app.contextGetToken(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"sessions": sessions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteSessionHandler revokes one of the user's sessions, which may be the current
+// one.
+func (app *application) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["session_id"], 10, 64)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Tokens.DeleteSession(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "session successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func (app *application) getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Type string
+		model.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.Type = app.readString(qs, "type", "")
+	v.Check(input.Type == "" || validator.In(input.Type, "dorama", "actor", "genre"), "type", "must be one of dorama, actor or genre")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	// Most recently deleted records come first unless the client asks otherwise.
+	input.Filters.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.Filters.SortSafelist = []string{"deleted_at", "name", "-deleted_at", "-name"}
+
+	if model.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, metadata, err := app.models.Trash.GetAll(input.Type, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"trash": items, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restoreDoramaHandler(w http.ResponseWriter, r *http.Request) {
+	app.restoreHandler(w, r, app.models.Doramas.Restore, "dorama")
+}
+
+func (app *application) restoreActorHandler(w http.ResponseWriter, r *http.Request) {
+	app.restoreHandler(w, r, app.models.Actors.Restore, "actor")
+}
+
+func (app *application) restoreGenreHandler(w http.ResponseWriter, r *http.Request) {
+	app.restoreHandler(w, r, app.models.Genres.Restore, "genre")
+}
+
+// restoreHandler is shared by the restore endpoints of the catalog resources, which
+// only differ in the model method that takes the record out of the trash.
+func (app *application) restoreHandler(w http.ResponseWriter, r *http.Request, restore func(id int, userID int64) error, name string) {
+	id, err := app.readIDParam(r, "id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = restore(id, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": name + " successfully restored"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeTrash runs until the server shuts down, permanently deleting trashed records
+// once they are older than the configured retention period, together with any images
+// stored for them.
+func (app *application) purgeTrash(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.quit:
+			return
+		case <-ticker.C:
+		}
+
+		purged, err := app.models.Trash.Purge(app.config.trash.retention)
+		if err != nil {
+			app.logger.Println(err)
+			continue
+		}
+		if len(purged) > 0 {
+			app.logger.Printf("purged %d records from the trash", len(purged))
+		}
+
+		for _, item := range purged {
+			var key string
+			switch item.Type {
+			case "dorama":
+				key = fmt.Sprintf("doramas/%d/poster", item.ID)
+			case "actor":
+				key = fmt.Sprintf("actors/%d/photo", item.ID)
+			default:
+				continue
+			}
+
+			err := app.deleteStoredImage(context.Background(), key)
+			if err != nil {
+				app.logger.Println(err)
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"net/http"
+	"strings"
 	"github.com/makooster/MCA/pkg/model"
 	"github.com/makooster/MCA/pkg/validator"
 	"time"
@@ -14,6 +15,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Name string `json:"name"`
 		Email string `json:"email"`
 		Password string `json:"password"`
+		MaturityLevel *int `json:"maturity_level"`
 	}
 	// Parse the request body into the anonymous struct.
 	err := app.readJSON(w, r, &input)
@@ -29,6 +31,10 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Name: input.Name,
 		Email: input.Email,
 		Activated: false,
+		MaturityLevel: model.MaturityAdult,
+	}
+	if input.MaturityLevel != nil {
+		user.MaturityLevel = *input.MaturityLevel
 	}
 	// Use the Password.Set() method to generate and store the hashed and plaintext
 	// passwords.
@@ -46,8 +52,14 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Insert the user data into the database.
-	err = app.models.Users.Insert(user)
+	// Insert the user with the "movies:read" and "reviews:write" permissions, and queue
+	// the welcome email with the activation token, all at once. The outbox worker sends
+	// the email, so the client doesn't have to wait for the SMTP server.
+	token, err := app.models.Users.InsertWithActivation(user, []string{"movies:read", "reviews:write"}, 3*24*time.Hour, &model.OutboxEmail{
+		Recipient: user.Email,
+		Template:  "user_welcome.tmpl",
+		Data:      map[string]interface{}{"name": user.Name},
+	})
 	if err != nil {
 		switch {
 			// If we get a ErrDuplicateEmail error, use the v.AddError() method to manually
@@ -62,36 +74,22 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Add the "movies:read" permission for the new user.
-	err = app.models.Permissions.AddForUser(user.ID, "movies:read")
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, model.ScopeActivation)
-	// Call the Send() method on our Mailer, passing in the user's email address,
-	// name of the template file, and the User struct containing the new user's data.
-	//err = app.mailer.Send(user.Email, "user_welcome.tmpl", user)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-
 	var res struct {
-		Token *string     `json:"token"`
+		Token *string     `json:"token,omitempty"`
 		User  *model.User `json:"user"`
 	}
 
-	res.Token = &token.Plaintext
+	// The token is only meant for the owner of the email address. With -expose-tokens
+	// it is handed back as well, so that accounts can be activated without a mail
+	// server.
+	if app.config.exposeTokens {
+		res.Token = &token.Plaintext
+	}
 	res.User = user
 
-	app.writeJSON(w, http.StatusCreated, envelope{"user": res}, nil)
-	
 	// Write a JSON response containing the user data along with a 201 Created status
 	// code.
-	// err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
+	err = app.writeJSON(w, http.StatusCreated, envelope{"user": res}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -161,4 +159,277 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 			app.serverErrorResponse(w, r, err)
 	}
 }
-	
\ No newline at end of file
+	
+// showCurrentUserHandler returns the account of the user making the request.
+func (app *application) showCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"user": app.contextGetUser(r)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateCurrentUserHandler lets users change their own account. Changing the password
+// or the email address needs the current password, and a new password signs out every
+// other session. A new email address isn't used until it has been confirmed with the
+// token returned here; see confirmEmailChangeHandler.
+func (app *application) updateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name            *string `json:"name"`
+		MaturityLevel   *int    `json:"maturity_level"`
+		Email           *string `json:"email"`
+		Password        *string `json:"password"`
+		CurrentPassword string  `json:"current_password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+	v := validator.New()
+
+	if input.Email != nil || input.Password != nil {
+		match, err := user.Password.Matches(input.CurrentPassword)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !match {
+			v.AddError("current_password", "must match your current password")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	if input.Name != nil {
+		user.Name = *input.Name
+	}
+	if input.MaturityLevel != nil {
+		user.MaturityLevel = *input.MaturityLevel
+	}
+	if input.Password != nil {
+		err = user.Password.Set(*input.Password)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// Asking for the address the account already has cancels a pending change. Addresses
+	// are stored case-insensitively, so the same address in another case counts too.
+	emailChanged := false
+	if input.Email != nil {
+		if strings.EqualFold(*input.Email, user.Email) {
+			user.PendingEmail = ""
+		} else {
+			model.ValidateEmail(v, *input.Email)
+			user.PendingEmail = *input.Email
+			emailChanged = true
+		}
+	}
+
+	if model.ValidateUser(v, user); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if emailChanged {
+		_, err = app.models.Users.GetByEmail(user.PendingEmail)
+		switch {
+			case err == nil:
+				v.AddError("email", "a user with this email address already exists")
+				app.failedValidationResponse(w, r, v.Errors)
+				return
+			case !errors.Is(err, model.ErrRecordNotFound):
+				app.serverErrorResponse(w, r, err)
+				return
+		}
+	}
+
+	// A new address is saved together with its confirmation token, which replaces any
+	// earlier one so that an address the user has since changed their mind about can't
+	// be confirmed any more. The token goes to the new address, which is what proves
+	// the user owns it.
+	var token *model.Token
+	if emailChanged {
+		token, err = app.models.Users.UpdateWithToken(user, 24*time.Hour, model.ScopeEmailChange, &model.OutboxEmail{
+			Recipient: user.PendingEmail,
+			Template:  "email_change.tmpl",
+			Data:      map[string]interface{}{"email": user.PendingEmail, "name": user.Name},
+		})
+	} else {
+		err = app.models.Users.Update(user)
+	}
+	if err != nil {
+		switch {
+			case errors.Is(err, model.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Whoever signed in with the old password, or asked for a reset link with it still
+	// in place, shouldn't keep access. The session making the change stays signed in.
+	if input.Password != nil {
+		err = app.models.Tokens.DeleteOtherSessions(user.ID, app.contextGetToken(r))
+		if err == nil {
+			err = app.models.Tokens.DeleteAllForUser(model.ScopePasswordReset, user.ID)
+		}
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if !emailChanged {
+		err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"user": user}
+	if app.config.exposeTokens {
+		env["email_confirmation_token"] = token
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// confirmEmailChangeHandler switches the account over to its pending email address
+// once the user proves they can read mail sent there.
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if model.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(model.ScopeEmailChange, input.TokenPlaintext)
+	if err == nil && user.PendingEmail == "" {
+		err = model.ErrRecordNotFound
+	}
+	if err != nil {
+		switch {
+			case errors.Is(err, model.ErrRecordNotFound):
+				v.AddError("token", "invalid or expired email confirmation token")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		switch {
+			case errors.Is(err, model.ErrDuplicateEmail):
+				v.AddError("email", "a user with this email address already exists")
+				app.failedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, model.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(model.ScopeEmailChange, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateUserPasswordHandler sets a new password for the user a password reset token
+// was issued to, and signs the user out everywhere.
+func (app *application) updateUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password       string `json:"password"`
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	model.ValidatePasswordPlaintext(v, input.Password)
+	model.ValidateTokenPlaintext(v, input.TokenPlaintext)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(model.ScopePasswordReset, input.TokenPlaintext)
+	if err != nil {
+		switch {
+			case errors.Is(err, model.ErrRecordNotFound):
+				v.AddError("token", "invalid or expired password reset token")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		switch {
+			case errors.Is(err, model.ErrEditConflict):
+				app.editConflictResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The reset token is used up, and whoever was signed in with the old password
+	// shouldn't stay signed in.
+	for _, scope := range []string{model.ScopePasswordReset, model.ScopeAuthentication} {
+		err = app.models.Tokens.DeleteAllForUser(scope, user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makooster/MCA/pkg/model"
+)
+
+func TestRegisterExposesTokenOnlyWhenAsked(t *testing.T) {
+	app := newTestApplication(t, true)
+
+	for _, exposeTokens := range []bool{false, true} {
+		t.Run(fmt.Sprintf("expose-tokens=%t", exposeTokens), func(t *testing.T) {
+			app.config.exposeTokens = exposeTokens
+
+			email := fmt.Sprintf("test-%d@example.com", time.Now().UnixNano())
+			t.Cleanup(func() {
+				app.models.Users.DB.Exec(`DELETE FROM users WHERE email = $1`, email)
+				app.models.Outbox.DB.Exec(`DELETE FROM email_outbox WHERE recipient = $1`, email)
+			})
+
+			body := fmt.Sprintf(`{"name": "Test user", "email": %q, "password": "pa55word1234"}`, email)
+			r := httptest.NewRequest(http.MethodPost, "/app/users", strings.NewReader(body))
+			rr := httptest.NewRecorder()
+			app.registerUserHandler(rr, r)
+
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("got status %d; want %d: %s", rr.Code, http.StatusCreated, rr.Body)
+			}
+			if got := strings.Contains(rr.Body.String(), `"token"`); got != exposeTokens {
+				t.Errorf("token in response: got %t; want %t", got, exposeTokens)
+			}
+		})
+	}
+}
+
+func TestUpdateEmailIgnoresCase(t *testing.T) {
+	app := newTestApplication(t, true)
+
+	user := &model.User{
+		Name:          "Test user",
+		Email:         fmt.Sprintf("Test-%d@Example.com", time.Now().UnixNano()),
+		Activated:     true,
+		MaturityLevel: model.MaturityAdult,
+	}
+	err := user.Password.Set("pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.models.Users.DB.Exec(`DELETE FROM users WHERE id = $1`, user.ID)
+	})
+
+	body := fmt.Sprintf(`{"email": %q, "current_password": "pa55word1234"}`, strings.ToLower(user.Email))
+	r := httptest.NewRequest(http.MethodPatch, "/app/users/me", strings.NewReader(body))
+	r = app.contextSetUser(r, user)
+	rr := httptest.NewRecorder()
+	app.updateCurrentUserHandler(rr, r)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d; want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+
+	got, err := app.models.Users.GetByEmail(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PendingEmail != "" {
+		t.Errorf("got pending email %q; want the change treated as the current address", got.PendingEmail)
+	}
+}
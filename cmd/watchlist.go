@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/makooster/MCA/pkg/model"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func (app *application) getWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		States []string
+		model.Filters
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	input.States = app.readCSV(qs, "state", []string{})
+	for i, state := range input.States {
+		input.States[i] = strings.TrimSpace(state)
+		v.Check(validator.In(input.States[i], model.WatchStates...), "state", "must be a comma-separated list of plan_to_watch, watching, completed, dropped or on_hold")
+	}
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-updated_at")
+	input.Filters.SortSafelist = []string{"title", "started_on", "finished_on", "created_at", "updated_at", "-title", "-started_on", "-finished_on", "-created_at", "-updated_at"}
+
+	if model.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, metadata, err := app.models.Watchlist.GetAll(app.contextGetUser(r).ID, input.States, app.contextGetUser(r).MaturityLevel, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"watchlist": entries, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getWatchlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "dorama_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	entry, err := app.models.Watchlist.Get(app.contextGetUser(r).ID, doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"entry": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// setWatchlistEntryHandler adds a dorama to the user's watchlist, or replaces the entry
+// if it is already there.
+func (app *application) setWatchlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "dorama_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		State           string `json:"state"`
+		EpisodesWatched int    `json:"episodes_watched"`
+		StartedOn       string `json:"started_on"`
+		FinishedOn      string `json:"finished_on"`
+		Notes           string `json:"notes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	dorama, err := app.models.Doramas.Get(doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	entry := &model.WatchlistEntry{
+		DoramaID:        dorama.DoramaId,
+		Title:           dorama.Title,
+		State:           input.State,
+		EpisodesWatched: input.EpisodesWatched,
+		EpisodeCount:    dorama.EpisodeCount,
+		StartedOn:       input.StartedOn,
+		FinishedOn:      input.FinishedOn,
+		Notes:           input.Notes,
+	}
+	if entry.State == "" {
+		entry.State = model.WatchStatePlanToWatch
+	}
+
+	app.saveWatchlistEntry(w, r, entry)
+}
+
+// patchWatchlistEntryHandler changes some fields of an existing entry, for instance to
+// bump the episode counter.
+func (app *application) patchWatchlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "dorama_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	entry, err := app.models.Watchlist.Get(app.contextGetUser(r).ID, doramaID, app.contextGetUser(r).MaturityLevel)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		State           *string `json:"state"`
+		EpisodesWatched *int    `json:"episodes_watched"`
+		StartedOn       *string `json:"started_on"`
+		FinishedOn      *string `json:"finished_on"`
+		Notes           *string `json:"notes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.State != nil {
+		entry.State = *input.State
+	}
+	if input.EpisodesWatched != nil {
+		entry.EpisodesWatched = *input.EpisodesWatched
+	}
+	if input.StartedOn != nil {
+		entry.StartedOn = *input.StartedOn
+	}
+	if input.FinishedOn != nil {
+		entry.FinishedOn = *input.FinishedOn
+	}
+	if input.Notes != nil {
+		entry.Notes = *input.Notes
+	}
+
+	app.saveWatchlistEntry(w, r, entry)
+}
+
+func (app *application) deleteWatchlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	doramaID, err := app.readIDParam(r, "dorama_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Watchlist.Delete(app.contextGetUser(r).ID, doramaID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "dorama successfully removed from watchlist"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// saveWatchlistEntry validates the entry and stores it for the current user. Marking a
+// dorama as completed counts all of its episodes as watched.
+func (app *application) saveWatchlistEntry(w http.ResponseWriter, r *http.Request, entry *model.WatchlistEntry) {
+	if entry.State == model.WatchStateCompleted && entry.EpisodeCount > 0 {
+		entry.EpisodesWatched = entry.EpisodeCount
+	}
+
+	v := validator.New()
+	if model.ValidateWatchlistEntry(v, entry); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.models.Watchlist.Set(app.contextGetUser(r).ID, entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"entry": entry}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,139 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooLarge        = errors.New("image dimensions too large")
+)
+
+// AllowedTypes lists the content types that can be uploaded, as reported by
+// http.DetectContentType.
+var AllowedTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// MaxPixels caps the decoded size of an upload. A small, highly compressed file can
+// otherwise expand into gigabytes of memory when decoded.
+const MaxPixels = 40_000_000
+
+// Image is an uploaded image that has been checked and decoded.
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	image       image.Image
+}
+
+// Decode sniffs the content type of data from its first bytes, ignoring whatever the
+// client claimed, and decodes it if it is one of the AllowedTypes.
+func Decode(data []byte) (*Image, error) {
+	contentType := http.DetectContentType(data)
+
+	allowed := false
+	for _, t := range AllowedTypes {
+		if contentType == t {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	return &Image{ContentType: contentType, Width: config.Width, Height: config.Height, image: img}, nil
+}
+
+// Thumbnail scales the image down to fit within maxWidth x maxHeight, keeping its
+// aspect ratio, and returns it encoded as a JPEG. Images that already fit are
+// re-encoded at their own size.
+func (img *Image) Thumbnail(maxWidth, maxHeight int) ([]byte, error) {
+	width, height := img.Width, img.Height
+	if width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if height > maxHeight {
+		width = width * maxHeight / height
+		height = maxHeight
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, resize(img.image, width, height), &jpeg.Options{Quality: 85})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resize scales src to width x height by averaging the source pixels that fall under
+// each destination pixel. That is slower than nearest-neighbour sampling but avoids
+// the aliasing you get when shrinking a poster by a large factor.
+func resize(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := bounds.Min.Y + (y+1)*srcHeight/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := bounds.Min.X + (x+1)*srcWidth/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,105 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// encodePNG returns a width x height PNG filled with a single colour.
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 30, B: 60, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	img, err := Decode(encodePNG(t, 30, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ContentType != "image/png" || img.Width != 30 || img.Height != 20 {
+		t.Errorf("got %s %dx%d; want image/png 30x20", img.ContentType, img.Width, img.Height)
+	}
+
+	_, err = Decode([]byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("svg: got %v; want ErrUnsupportedType", err)
+	}
+
+	// A PNG signature followed by garbage sniffs as a PNG but can't be decoded.
+	_, err = Decode(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("truncated png: got %v; want ErrUnsupportedType", err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name                  string
+		width, height         int
+		maxWidth, maxHeight   int
+		wantWidth, wantHeight int
+	}{
+		{"fits already", 100, 150, 300, 450, 100, 150},
+		{"wider than allowed", 600, 300, 300, 450, 300, 150},
+		{"taller than allowed", 300, 900, 300, 450, 150, 450},
+		{"both too large", 1200, 1200, 300, 450, 300, 300},
+		{"exact fit", 300, 450, 300, 450, 300, 450},
+		{"sliver", 1000, 1, 100, 100, 100, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Decode(encodePNG(t, tt.width, tt.height))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := img.Thumbnail(tt.maxWidth, tt.maxHeight)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			thumb, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("thumbnail is not a JPEG: %v", err)
+			}
+
+			bounds := thumb.Bounds()
+			if bounds.Dx() != tt.wantWidth || bounds.Dy() != tt.wantHeight {
+				t.Errorf("got %dx%d; want %dx%d", bounds.Dx(), bounds.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+
+			// Averaging a single colour must give that colour back, give or take
+			// what JPEG compression does to it.
+			r, g, b, _ := thumb.At(bounds.Dx()/2, bounds.Dy()/2).RGBA()
+			if diff(r>>8, 200) > 8 || diff(g>>8, 30) > 8 || diff(b>>8, 60) > 8 {
+				t.Errorf("got colour %d,%d,%d; want about 200,30,60", r>>8, g>>8, b>>8)
+			}
+		})
+	}
+}
+
+func diff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
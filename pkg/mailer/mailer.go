@@ -0,0 +1,252 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// templateFS holds the email templates. Each template defines a "subject", a
+// "plainBody" and an "htmlBody".
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// Mailer renders email templates and sends them through an SMTP server, or writes them
+// to a maildir when it was created with NewFile.
+type Mailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	sender   *mail.Address
+	timeout  time.Duration
+	// dir is the maildir messages are written to instead of being sent.
+	dir string
+}
+
+// New returns a Mailer that sends from sender, which may include a display name as in
+// "MCA <no-reply@example.com>". No connection is made until the first Send.
+func New(host string, port int, username, password, sender string) (*Mailer, error) {
+	from, err := mail.ParseAddress(sender)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", sender, err)
+	}
+
+	return &Mailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		sender:   from,
+		timeout:  10 * time.Second,
+	}, nil
+}
+
+// NewFile returns a Mailer that doesn't send anything, but writes each message as an
+// .eml file into the "new" directory of a maildir at dir, creating it if needed. This
+// is meant for development and tests, where there is no mail server to talk to.
+func NewFile(dir, sender string) (*Mailer, error) {
+	from, err := mail.ParseAddress(sender)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", sender, err)
+	}
+
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		err = os.MkdirAll(filepath.Join(dir, sub), 0o755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Mailer{sender: from, dir: dir}, nil
+}
+
+// Send renders the named template with data and mails it to recipient.
+func (m *Mailer) Send(recipient, templateFile string, data interface{}) error {
+	msg, err := m.message(recipient, templateFile, data)
+	if err != nil {
+		return err
+	}
+	if m.dir != "" {
+		return m.write(msg)
+	}
+	return m.send(recipient, msg)
+}
+
+// message renders the template into a complete multipart/alternative message with a
+// plain-text and an HTML version of the body.
+func (m *Mailer) message(recipient, templateFile string, data interface{}) ([]byte, error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The HTML body goes through html/template so that user-supplied values such as
+	// names are escaped.
+	htmlTmpl, err := htmltemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	err = htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=UTF-8", plainBody.Bytes()},
+		{"text/html; charset=UTF-8", htmlBody.Bytes()},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qw := quotedprintable.NewWriter(pw)
+		_, err = qw.Write(part.content)
+		if err != nil {
+			return nil, err
+		}
+		err = qw.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := new(bytes.Buffer)
+	fmt.Fprintf(msg, "From: %s\r\n", m.sender.String())
+	fmt.Fprintf(msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject.String()))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// send delivers a rendered message, upgrading the connection with STARTTLS and
+// authenticating when the server supports it.
+func (m *Mailer) send(recipient string, msg []byte) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(m.host, strconv.Itoa(m.port)), m.timeout)
+	if err != nil {
+		return err
+	}
+
+	err = conn.SetDeadline(time.Now().Add(m.timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: m.host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if ok, _ := c.Extension("AUTH"); ok && m.username != "" {
+		err = c.Auth(smtp.PlainAuth("", m.username, m.password, m.host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(m.sender.Address)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(recipient)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// write stores a rendered message in the maildir. It is written under tmp first and
+// then moved into new, so that readers never see a half-written file.
+func (m *Mailer) write(msg []byte) error {
+	// Maildir names only have to be unique; the random part CreateTemp adds takes
+	// care of messages written in the same nanosecond.
+	pattern := fmt.Sprintf("%d.%d.*", time.Now().UnixNano(), os.Getpid())
+
+	f, err := os.CreateTemp(filepath.Join(m.dir, "tmp"), pattern)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(msg)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(m.dir, "new", filepath.Base(f.Name())+".eml"))
+}
@@ -0,0 +1,163 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parts reads a message rendered by Mailer.message, and returns its headers and the
+// body of each part by content type. The multipart reader undoes the quoted-printable
+// encoding of the parts.
+func parts(t *testing.T, msg []byte) (mail.Header, map[string]string) {
+	t.Helper()
+
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("got content type %q; want multipart/alternative", mediaType)
+	}
+
+	bodies := make(map[string]string)
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies[p.Header.Get("Content-Type")] = string(body)
+	}
+	return m.Header, bodies
+}
+
+func TestMessage(t *testing.T) {
+	m, err := New("localhost", 25, "", "", "MCA <no-reply@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"name":   "<b>Jisoo</b>",
+		"email":  "new@example.com",
+		"userID": json.Number("1234567"),
+		"token":  "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+	}
+
+	templates := []string{"email_change.tmpl", "password_reset.tmpl", "token_activation.tmpl", "user_welcome.tmpl"}
+	for _, tmpl := range templates {
+		t.Run(tmpl, func(t *testing.T) {
+			msg, err := m.message("jisoo@example.com", tmpl, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n := bytes.Count(msg, []byte("Content-Transfer-Encoding: quoted-printable")); n != 2 {
+				t.Errorf("got %d quoted-printable parts; want 2", n)
+			}
+
+			header, bodies := parts(t, msg)
+
+			if got := header.Get("From"); got != `"MCA" <no-reply@example.com>` {
+				t.Errorf("got From %q", got)
+			}
+			if got := header.Get("To"); got != "jisoo@example.com" {
+				t.Errorf("got To %q", got)
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+			if err != nil || subject == "" {
+				t.Errorf("got Subject %q, %v; want a subject", subject, err)
+			}
+
+			plain, ok := bodies["text/plain; charset=UTF-8"]
+			if !ok {
+				t.Fatal("no plain-text part")
+			}
+			html, ok := bodies["text/html; charset=UTF-8"]
+			if !ok {
+				t.Fatal("no HTML part")
+			}
+
+			for name, body := range map[string]string{"plain-text": plain, "HTML": html} {
+				if !strings.Contains(body, data["token"].(string)) {
+					t.Errorf("%s part doesn't carry the token", name)
+				}
+			}
+			if strings.Contains(html, "<b>Jisoo</b>") {
+				t.Error("HTML part doesn't escape the name")
+			}
+		})
+	}
+
+	t.Run("unknown template", func(t *testing.T) {
+		_, err := m.message("jisoo@example.com", "missing.tmpl", data)
+		if err == nil {
+			t.Error("got no error")
+		}
+	})
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	m, err := NewFile(dir, "MCA <no-reply@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = m.Send("jisoo@example.com", "token_activation.tmpl", map[string]interface{}{"token": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tmp, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmp) != 0 {
+		t.Errorf("got %d files left in tmp; want none", len(tmp))
+	}
+
+	delivered, err := os.ReadDir(filepath.Join(dir, "new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 2 {
+		t.Fatalf("got %d files in new; want 2", len(delivered))
+	}
+
+	for _, entry := range delivered {
+		if filepath.Ext(entry.Name()) != ".eml" {
+			t.Errorf("got file %q; want an .eml file", entry.Name())
+		}
+
+		msg, err := os.ReadFile(filepath.Join(dir, "new", entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts(t, msg)
+	}
+}
@@ -0,0 +1,17 @@
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS main_actors text;
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS dorama_id integer REFERENCES doramas(dorama_id);
+
+UPDATE doramas SET main_actors = (
+    SELECT string_agg(actors.full_name, ', ' ORDER BY doramas_actors.billing_order)
+    FROM doramas_actors
+    INNER JOIN actors ON actors.id = doramas_actors.actor_id
+    WHERE doramas_actors.dorama_id = doramas.dorama_id AND doramas_actors.is_lead
+);
+
+UPDATE actors SET dorama_id = (
+    SELECT min(doramas_actors.dorama_id)
+    FROM doramas_actors
+    WHERE doramas_actors.actor_id = actors.id
+);
+
+DROP TABLE doramas_actors;
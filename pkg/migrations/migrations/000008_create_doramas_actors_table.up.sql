@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS doramas_actors (
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    actor_id integer NOT NULL REFERENCES actors(id) ON DELETE CASCADE,
+    character_name text NOT NULL DEFAULT '',
+    billing_order integer NOT NULL DEFAULT 0,
+    is_lead bool NOT NULL DEFAULT false,
+    PRIMARY KEY (dorama_id, actor_id)
+);
+
+CREATE INDEX IF NOT EXISTS doramas_actors_actor_id_idx ON doramas_actors (actor_id);
+
+-- Every actor row used to point at exactly one dorama, so keep that link as a lead role.
+INSERT INTO doramas_actors (dorama_id, actor_id, is_lead)
+SELECT dorama_id, id, true
+FROM actors
+WHERE dorama_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+-- main_actors is a comma separated list of names. Create actor records for any names we
+-- don't know yet, then link them in the order they were listed. A name listed twice is
+-- linked once, at its first position.
+INSERT INTO actors (full_name)
+SELECT DISTINCT trim(name)
+FROM doramas, regexp_split_to_table(doramas.main_actors, ',') AS name
+WHERE trim(name) <> ''
+AND NOT EXISTS (SELECT 1 FROM actors WHERE actors.full_name = trim(name));
+
+INSERT INTO doramas_actors (dorama_id, actor_id, billing_order, is_lead)
+SELECT doramas.dorama_id, actors.id, min(names.ordinality), true
+FROM doramas
+CROSS JOIN LATERAL regexp_split_to_table(doramas.main_actors, ',') WITH ORDINALITY AS names(name, ordinality)
+INNER JOIN actors ON actors.full_name = trim(names.name)
+GROUP BY doramas.dorama_id, actors.id
+ON CONFLICT (dorama_id, actor_id) DO UPDATE SET billing_order = EXCLUDED.billing_order;
+
+ALTER TABLE doramas DROP COLUMN IF EXISTS main_actors;
+ALTER TABLE actors DROP COLUMN IF EXISTS dorama_id;
@@ -0,0 +1,9 @@
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS genre_id integer REFERENCES genres(genre_id);
+
+UPDATE doramas SET genre_id = (
+    SELECT min(doramas_genres.genre_id)
+    FROM doramas_genres
+    WHERE doramas_genres.dorama_id = doramas.dorama_id
+);
+
+DROP TABLE doramas_genres;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS doramas_genres (
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    genre_id integer NOT NULL REFERENCES genres(genre_id) ON DELETE CASCADE,
+    PRIMARY KEY (dorama_id, genre_id)
+);
+
+CREATE INDEX IF NOT EXISTS doramas_genres_genre_id_idx ON doramas_genres (genre_id);
+
+INSERT INTO doramas_genres (dorama_id, genre_id)
+SELECT dorama_id, genre_id
+FROM doramas
+WHERE genre_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE doramas DROP COLUMN IF EXISTS genre_id;
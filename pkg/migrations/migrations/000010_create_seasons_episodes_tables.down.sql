@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS episodes;
+DROP TABLE IF EXISTS seasons;
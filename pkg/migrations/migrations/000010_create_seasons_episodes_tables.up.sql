@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS seasons (
+    id serial PRIMARY KEY,
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    season_number integer NOT NULL,
+    title text NOT NULL DEFAULT '',
+    air_date date,
+    synopsis text NOT NULL DEFAULT '',
+    UNIQUE (dorama_id, season_number)
+);
+
+CREATE TABLE IF NOT EXISTS episodes (
+    id serial PRIMARY KEY,
+    dorama_id integer NOT NULL,
+    season_number integer NOT NULL,
+    episode_number integer NOT NULL,
+    title text NOT NULL DEFAULT '',
+    air_date date,
+    runtime integer NOT NULL DEFAULT 0,
+    synopsis text NOT NULL DEFAULT '',
+    UNIQUE (dorama_id, season_number, episode_number),
+    FOREIGN KEY (dorama_id, season_number) REFERENCES seasons(dorama_id, season_number) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,3 @@
+ALTER TABLE doramas DROP COLUMN IF EXISTS version;
+ALTER TABLE actors DROP COLUMN IF EXISTS version;
+ALTER TABLE genres DROP COLUMN IF EXISTS version;
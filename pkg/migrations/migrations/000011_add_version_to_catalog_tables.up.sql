@@ -0,0 +1,3 @@
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE genres ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+DELETE FROM permissions WHERE code = 'movies:admin';
+
+DELETE FROM doramas WHERE deleted_at IS NOT NULL;
+DELETE FROM actors WHERE deleted_at IS NOT NULL;
+DELETE FROM genres WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE doramas DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE actors DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE genres DROP COLUMN IF EXISTS deleted_at;
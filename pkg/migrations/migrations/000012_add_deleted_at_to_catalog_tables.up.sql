@@ -0,0 +1,11 @@
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE genres ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS doramas_deleted_at_idx ON doramas (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS actors_deleted_at_idx ON actors (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS genres_deleted_at_idx ON genres (deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO permissions (code)
+SELECT 'movies:admin'
+WHERE NOT EXISTS (SELECT 1 FROM permissions WHERE code = 'movies:admin');
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS revisions (
+    id bigserial PRIMARY KEY,
+    entity_type text NOT NULL,
+    entity_id integer NOT NULL,
+    revision integer NOT NULL,
+    action text NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    before jsonb,
+    after jsonb,
+    UNIQUE (entity_type, entity_id, revision)
+);
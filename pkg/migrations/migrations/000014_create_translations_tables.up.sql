@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS dorama_translations (
+    dorama_id integer NOT NULL REFERENCES doramas ON DELETE CASCADE,
+    language text NOT NULL,
+    title text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    PRIMARY KEY (dorama_id, language)
+);
+
+CREATE TABLE IF NOT EXISTS genre_translations (
+    genre_id integer NOT NULL REFERENCES genres ON DELETE CASCADE,
+    language text NOT NULL,
+    genre_name text NOT NULL,
+    PRIMARY KEY (genre_id, language)
+);
+
+CREATE INDEX IF NOT EXISTS dorama_translations_title_idx ON dorama_translations USING GIN (to_tsvector('simple', title));
+
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS dorama_aliases (
+    id serial PRIMARY KEY,
+    dorama_id integer NOT NULL REFERENCES doramas ON DELETE CASCADE,
+    alias text NOT NULL,
+    kind text NOT NULL DEFAULT 'other',
+    language text NOT NULL DEFAULT '',
+    UNIQUE (dorama_id, alias)
+);
+
+CREATE INDEX IF NOT EXISTS dorama_aliases_alias_idx ON dorama_aliases USING GIN (to_tsvector('simple', alias));
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS doramas_countries;
+DROP TABLE IF EXISTS doramas_companies;
+DROP TABLE IF EXISTS countries;
+DROP TABLE IF EXISTS companies;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS companies (
+    company_id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    kind text NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS countries (
+    country_id SERIAL PRIMARY KEY,
+    code CHAR(2) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS doramas_companies (
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    company_id integer NOT NULL REFERENCES companies(company_id) ON DELETE CASCADE,
+    PRIMARY KEY (dorama_id, company_id)
+);
+
+CREATE TABLE IF NOT EXISTS doramas_countries (
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    country_id integer NOT NULL REFERENCES countries(country_id) ON DELETE CASCADE,
+    PRIMARY KEY (dorama_id, country_id)
+);
+
+CREATE INDEX IF NOT EXISTS doramas_companies_company_id_idx ON doramas_companies (company_id);
+CREATE INDEX IF NOT EXISTS doramas_countries_country_id_idx ON doramas_countries (country_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS broadcast_slots;
+DROP INDEX IF EXISTS doramas_status_idx;
+ALTER TABLE doramas DROP COLUMN IF EXISTS end_date;
+ALTER TABLE doramas DROP COLUMN IF EXISTS start_date;
+ALTER TABLE doramas DROP COLUMN IF EXISTS status;
@@ -0,0 +1,18 @@
+-- Everything already in the catalog was entered as a finished show.
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'finished';
+ALTER TABLE doramas ALTER COLUMN status SET DEFAULT 'announced';
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS start_date date;
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS end_date date;
+
+CREATE INDEX IF NOT EXISTS doramas_status_idx ON doramas (status);
+
+-- A weekly broadcast slot: the dorama airs every week on weekday (ISO numbering, 1 is
+-- Monday) at air_time in the given time zone.
+CREATE TABLE IF NOT EXISTS broadcast_slots (
+    id serial PRIMARY KEY,
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    weekday smallint NOT NULL CHECK (weekday BETWEEN 1 AND 7),
+    air_time time NOT NULL,
+    timezone text NOT NULL DEFAULT 'Asia/Seoul',
+    UNIQUE (dorama_id, weekday, air_time)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE actors DROP COLUMN IF EXISTS photo_thumbnail_url;
+ALTER TABLE actors DROP COLUMN IF EXISTS photo_url;
+ALTER TABLE doramas DROP COLUMN IF EXISTS poster_thumbnail_url;
+ALTER TABLE doramas DROP COLUMN IF EXISTS poster_url;
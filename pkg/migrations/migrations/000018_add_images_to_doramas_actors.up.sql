@@ -0,0 +1,4 @@
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS poster_url text NOT NULL DEFAULT '';
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS poster_thumbnail_url text NOT NULL DEFAULT '';
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS photo_url text NOT NULL DEFAULT '';
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS photo_thumbnail_url text NOT NULL DEFAULT '';
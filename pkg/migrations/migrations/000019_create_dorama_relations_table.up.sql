@@ -0,0 +1,12 @@
+-- A row reads "dorama_id is a <relation> of related_id", e.g. a remake of the original.
+CREATE TABLE IF NOT EXISTS dorama_relations (
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    related_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    relation text NOT NULL,
+    PRIMARY KEY (dorama_id, related_id),
+    CHECK (dorama_id <> related_id)
+);
+
+-- Two doramas can only be related one way round.
+CREATE UNIQUE INDEX IF NOT EXISTS dorama_relations_pair_idx ON dorama_relations (LEAST(dorama_id, related_id), GREATEST(dorama_id, related_id));
+CREATE INDEX IF NOT EXISTS dorama_relations_related_id_idx ON dorama_relations (related_id);
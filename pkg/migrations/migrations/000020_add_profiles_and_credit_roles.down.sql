@@ -0,0 +1,12 @@
+DELETE FROM doramas_actors WHERE role <> 'actor';
+ALTER TABLE doramas_actors DROP CONSTRAINT IF EXISTS doramas_actors_pkey;
+ALTER TABLE doramas_actors ADD PRIMARY KEY (dorama_id, actor_id);
+DROP INDEX IF EXISTS doramas_actors_role_idx;
+ALTER TABLE doramas_actors DROP CONSTRAINT IF EXISTS doramas_actors_role_check;
+ALTER TABLE doramas_actors DROP COLUMN IF EXISTS role;
+
+ALTER TABLE actors DROP COLUMN IF EXISTS links;
+ALTER TABLE actors DROP COLUMN IF EXISTS agency;
+ALTER TABLE actors DROP COLUMN IF EXISTS biography;
+ALTER TABLE actors DROP COLUMN IF EXISTS nationality;
+ALTER TABLE actors DROP COLUMN IF EXISTS birth_date;
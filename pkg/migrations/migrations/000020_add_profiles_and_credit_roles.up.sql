@@ -0,0 +1,13 @@
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS birth_date date;
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS nationality text NOT NULL DEFAULT '';
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS biography text NOT NULL DEFAULT '';
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS agency text NOT NULL DEFAULT '';
+ALTER TABLE actors ADD COLUMN IF NOT EXISTS links jsonb NOT NULL DEFAULT '[]';
+
+-- The cast table now holds every kind of credit, so the same person can appear in a
+-- dorama both in front of and behind the camera. Existing rows are all acting credits.
+ALTER TABLE doramas_actors ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'actor';
+ALTER TABLE doramas_actors ADD CONSTRAINT doramas_actors_role_check CHECK (role IN ('actor', 'director', 'screenwriter', 'composer', 'producer'));
+ALTER TABLE doramas_actors DROP CONSTRAINT IF EXISTS doramas_actors_pkey;
+ALTER TABLE doramas_actors ADD PRIMARY KEY (dorama_id, actor_id, role);
+CREATE INDEX IF NOT EXISTS doramas_actors_role_idx ON doramas_actors (role);
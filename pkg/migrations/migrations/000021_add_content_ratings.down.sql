@@ -0,0 +1,8 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_maturity_level_check;
+ALTER TABLE users DROP COLUMN IF EXISTS maturity_level;
+
+DROP INDEX IF EXISTS doramas_min_age_idx;
+ALTER TABLE doramas DROP COLUMN IF EXISTS content_warnings;
+ALTER TABLE doramas DROP COLUMN IF EXISTS min_age;
+ALTER TABLE doramas DROP COLUMN IF EXISTS content_rating;
+ALTER TABLE doramas DROP COLUMN IF EXISTS rating_system;
@@ -0,0 +1,11 @@
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS rating_system text NOT NULL DEFAULT '';
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS content_rating text NOT NULL DEFAULT '';
+-- min_age is the rating translated into an age, so that ratings from different systems
+-- can be compared with a user's maturity level. Unrated doramas count as all-ages.
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS min_age integer NOT NULL DEFAULT 0;
+ALTER TABLE doramas ADD COLUMN IF NOT EXISTS content_warnings text[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS doramas_min_age_idx ON doramas (min_age);
+
+-- Existing accounts keep seeing the whole catalogue.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS maturity_level integer NOT NULL DEFAULT 18;
+ALTER TABLE users ADD CONSTRAINT users_maturity_level_check CHECK (maturity_level BETWEEN 0 AND 18);
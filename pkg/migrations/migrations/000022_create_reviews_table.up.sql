@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS reviews (
+    id bigserial PRIMARY KEY,
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    score integer NOT NULL CHECK (score BETWEEN 1 AND 10),
+    body text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    UNIQUE (dorama_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_user_id_idx ON reviews (user_id);
+
+INSERT INTO permissions (code)
+SELECT 'reviews:write'
+WHERE NOT EXISTS (SELECT 1 FROM permissions WHERE code = 'reviews:write');
+
+-- New users get the permission when they register; give it to everyone already here.
+INSERT INTO users_permissions (user_id, permission_id)
+SELECT users.id, permissions.id
+FROM users
+CROSS JOIN permissions
+WHERE permissions.code = 'reviews:write'
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS watchlist_entries (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    dorama_id integer NOT NULL REFERENCES doramas(dorama_id) ON DELETE CASCADE,
+    state text NOT NULL CHECK (state IN ('plan_to_watch', 'watching', 'completed', 'dropped', 'on_hold')),
+    episodes_watched integer NOT NULL DEFAULT 0 CHECK (episodes_watched >= 0),
+    started_on date,
+    finished_on date,
+    notes text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, dorama_id)
+);
+
+CREATE INDEX IF NOT EXISTS watchlist_entries_dorama_id_idx ON watchlist_entries (dorama_id);
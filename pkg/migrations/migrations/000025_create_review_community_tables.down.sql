@@ -0,0 +1,6 @@
+DELETE FROM permissions WHERE code = 'content:moderate';
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS moderation_actions;
+DROP TABLE IF EXISTS review_likes;
+DROP TABLE IF EXISTS review_comments;
+ALTER TABLE reviews DROP COLUMN IF EXISTS hidden_at;
@@ -0,0 +1,59 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS hidden_at timestamp(0) with time zone;
+
+CREATE TABLE IF NOT EXISTS review_comments (
+    id bigserial PRIMARY KEY,
+    review_id bigint NOT NULL REFERENCES reviews ON DELETE CASCADE,
+    parent_id bigint REFERENCES review_comments ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    body text NOT NULL,
+    hidden_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS review_comments_review_id_idx ON review_comments (review_id) WHERE parent_id IS NULL;
+CREATE INDEX IF NOT EXISTS review_comments_parent_id_idx ON review_comments (parent_id);
+
+CREATE TABLE IF NOT EXISTS review_likes (
+    review_id bigint NOT NULL REFERENCES reviews ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (review_id, user_id)
+);
+
+-- Moderation actions are the audit trail, so they keep no foreign keys to the content
+-- or the moderator: the record has to outlive both.
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id bigserial PRIMARY KEY,
+    target_type text NOT NULL CHECK (target_type IN ('review', 'comment')),
+    target_id bigint NOT NULL,
+    moderator_id bigint NOT NULL,
+    action text NOT NULL CHECK (action IN ('hide', 'restore', 'dismiss')),
+    reason text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS moderation_actions_target_idx ON moderation_actions (target_type, target_id);
+
+CREATE TABLE IF NOT EXISTS reports (
+    id bigserial PRIMARY KEY,
+    review_id bigint REFERENCES reviews ON DELETE CASCADE,
+    comment_id bigint REFERENCES review_comments ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    reason text NOT NULL CHECK (reason IN ('spam', 'harassment', 'spoiler', 'off_topic', 'other')),
+    details text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    resolved_at timestamp(0) with time zone,
+    action_id bigint REFERENCES moderation_actions ON DELETE SET NULL,
+    CHECK (num_nonnulls(review_id, comment_id) = 1)
+);
+
+-- A user can report the same piece of content once.
+CREATE UNIQUE INDEX IF NOT EXISTS reports_review_user_idx ON reports (review_id, user_id) WHERE review_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reports_comment_user_idx ON reports (comment_id, user_id) WHERE comment_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reports_open_idx ON reports (created_at) WHERE resolved_at IS NULL;
+
+INSERT INTO permissions (code)
+SELECT 'content:moderate'
+WHERE NOT EXISTS (SELECT 1 FROM permissions WHERE code = 'content:moderate');
@@ -0,0 +1,3 @@
+-- A withdrawn comment that has replies is blanked rather than deleted, so that the
+-- thread below it stays in place.
+ALTER TABLE review_comments ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
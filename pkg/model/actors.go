@@ -3,16 +3,56 @@ package model
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"time"
 	"log"
 	"fmt"
+	"net/url"
+
+	"github.com/makooster/MCA/pkg/validator"
 )
 
 type Actor struct {
 	ActorId int    `json:"id"`
 	Name    string `json:"full_name"`
-	DoramaID  int  `json:"dorama_id"`
+	Version int    `json:"version"`
+	// The photo is uploaded separately, and these are ignored on insert and update.
+	PhotoURL          string `json:"photo_url,omitempty"`
+	PhotoThumbnailURL string `json:"photo_thumbnail_url,omitempty"`
+	BirthDate         string `json:"birth_date,omitempty"`
+	Nationality       string `json:"nationality,omitempty"`
+	Biography         string `json:"biography,omitempty"`
+	Agency            string `json:"agency,omitempty"`
+	Links             []Link `json:"links"`
+	// CreditCount is only filled in by GetAll, so that it doesn't end up in revisions.
+	CreditCount int `json:"credit_count,omitempty"`
+}
+
+// Link points at a person's page on another site, such as a fan cafe or an agency
+// profile.
+type Link struct {
+	Site string `json:"site"`
+	URL  string `json:"url"`
+}
+
+func ValidateActor(v *validator.Validator, actor *Actor) {
+	v.Check(actor.Name != "", "full_name", "must be provided")
+	v.Check(len(actor.Name) <= 255, "full_name", "must not be more than 255 bytes long")
+
+	v.Check(actor.BirthDate == "" || validator.Date(actor.BirthDate), "birth_date", "must be a date in YYYY-MM-DD format")
+	v.Check(actor.BirthDate == "" || actor.BirthDate <= time.Now().Format("2006-01-02"), "birth_date", "must not be in the future")
+	v.Check(actor.Nationality == "" || validator.Matches(actor.Nationality, CountryCodeRX), "nationality", "must be a two-letter ISO 3166-1 country code")
+	v.Check(len(actor.Biography) <= 10000, "biography", "must not be more than 10000 bytes long")
+	v.Check(len(actor.Agency) <= 255, "agency", "must not be more than 255 bytes long")
+
+	v.Check(len(actor.Links) <= 20, "links", "must not contain more than 20 links")
+	for _, link := range actor.Links {
+		v.Check(link.Site != "", "links", "every link must have a site")
+		v.Check(len(link.Site) <= 100, "links", "site names must not be more than 100 bytes long")
+		u, err := url.Parse(link.URL)
+		v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "links", "every link must have an absolute http or https URL")
+	}
 }
 
 type ActorModel struct {
@@ -23,16 +63,24 @@ type ActorModel struct {
 
 
 
-func (m ActorModel) GetAll(fullName string, doramaID int, filters Filters) ([]*Actor, Metadata, error) {
-	// Retrieve all actors from the database.
+func (m ActorModel) GetAll(fullName string, doramaID int, role string, filters Filters) ([]*Actor, Metadata, error) {
+	// Retrieve all actors from the database. The dorama and role filters go through the
+	// credits table, since a person can be credited in any number of doramas and roles.
+	// credit_count only counts credits in doramas that aren't in the trash, and can be
+	// sorted on like a column.
 	query := fmt.Sprintf(
 		`
-		SELECT count(*) OVER(), id, full_name, dorama_id
+		SELECT count(*) OVER(), id, full_name, version, photo_url, photo_thumbnail_url,
+			COALESCE(to_char(birth_date, 'YYYY-MM-DD'), ''), nationality, biography, agency, links,
+			(SELECT count(*) FROM doramas_actors INNER JOIN doramas ON doramas.dorama_id = doramas_actors.dorama_id
+				WHERE doramas_actors.actor_id = actors.id AND doramas.deleted_at IS NULL) AS credit_count
 		FROM actors
-		WHERE (to_tsvector('simple', full_name) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (dorama_id = $2 OR $2 = 1)
-		ORDER BY %s %s, dorama_id
-		LIMIT $3 OFFSET $4`,
+		WHERE deleted_at IS NULL
+		AND (to_tsvector('simple', full_name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND (EXISTS (SELECT 1 FROM doramas_actors WHERE doramas_actors.actor_id = actors.id AND doramas_actors.dorama_id = $2) OR $2 = 0)
+		AND (EXISTS (SELECT 1 FROM doramas_actors WHERE doramas_actors.actor_id = actors.id AND doramas_actors.role = $3) OR $3 = '')
+		ORDER BY %s %s, id
+		LIMIT $4 OFFSET $5`,
 	filters.sortColumn(), filters.sortDirection())
 
 	// Create a context with a 3-second timeout.
@@ -40,7 +88,7 @@ func (m ActorModel) GetAll(fullName string, doramaID int, filters Filters) ([]*A
 	defer cancel()
 
 	// Organize our placeholder parameter values in a slice.
-	args := []interface{}{fullName, doramaID, filters.PageSize, filters.Page}
+	args := []interface{}{fullName, doramaID, role, filters.limit(), filters.offset()}
 
 	// Use QueryContext to execute the query.
 	rows, err := m.DB.QueryContext(ctx, query, args...)
@@ -59,7 +107,13 @@ func (m ActorModel) GetAll(fullName string, doramaID int, filters Filters) ([]*A
 	var actors []*Actor
 	for rows.Next() {
 		var actor Actor
-		err := rows.Scan(&totalRecords, &actor.ActorId, &actor.Name, &actor.DoramaID)
+		var links []byte
+		err := rows.Scan(&totalRecords, &actor.ActorId, &actor.Name, &actor.Version, &actor.PhotoURL, &actor.PhotoThumbnailURL,
+			&actor.BirthDate, &actor.Nationality, &actor.Biography, &actor.Agency, &links, &actor.CreditCount)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		err = json.Unmarshal(links, &actor.Links)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -77,63 +131,244 @@ func (m ActorModel) GetAll(fullName string, doramaID int, filters Filters) ([]*A
 	return actors, metadata, nil
 }
 func (am *ActorModel) Get(id int) (*Actor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return am.get(ctx, am.DB, id)
+}
+
+// get looks up a live actor through q, which is either the connection pool or a
+// transaction that is about to modify the same record.
+func (am *ActorModel) get(ctx context.Context, q queryer, id int) (*Actor, error) {
 	query := `
-        SELECT id, full_name, dorama_id
+        SELECT id, full_name, version, photo_url, photo_thumbnail_url,
+            COALESCE(to_char(birth_date, 'YYYY-MM-DD'), ''), nationality, biography, agency, links
         FROM actors
-        WHERE id = $1
+        WHERE id = $1 AND deleted_at IS NULL
     `
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
 
 	actor := &Actor{}
-	err := am.DB.QueryRowContext(ctx, query, id).Scan(&actor.ActorId, &actor.Name, &actor.DoramaID)
+	var links []byte
+	err := q.QueryRowContext(ctx, query, id).Scan(&actor.ActorId, &actor.Name, &actor.Version, &actor.PhotoURL, &actor.PhotoThumbnailURL,
+		&actor.BirthDate, &actor.Nationality, &actor.Biography, &actor.Agency, &links)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("actor not found")
+			return nil, ErrRecordNotFound
 		} else {
 			return nil, err
 		} 
 	}
 
+	err = json.Unmarshal(links, &actor.Links)
+	if err != nil {
+		return nil, err
+	}
+
 	return actor, nil
 }
 
-func (am *ActorModel) Insert(actor *Actor) error {
+// Insert adds a new actor and records the change as made by userID.
+func (am *ActorModel) Insert(actor *Actor, userID int64) error {
 	query := `
-		INSERT INTO actors (full_name, dorama_id) 
-		VALUES ($1, $2) 
-		RETURNING id
+		INSERT INTO actors (full_name, birth_date, nationality, biography, agency, links) 
+		VALUES ($1, NULLIF($2, '')::date, $3, $4, $5, $6) 
+		RETURNING id, version
 		`
-	args := []interface{}{actor.Name, actor.DoramaID}
+	if actor.Links == nil {
+		actor.Links = []Link{}
+	}
+	links, err := json.Marshal(actor.Links)
+	if err != nil {
+		return err
+	}
+	args := []interface{}{actor.Name, actor.BirthDate, actor.Nationality, actor.Biography, actor.Agency, links}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return am.DB.QueryRowContext(ctx, query, args...).Scan(&actor.ActorId)
+	tx, err := am.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&actor.ActorId, &actor.Version)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "actor", actor.ActorId, actor.Version, RevisionInsert, userID, nil, actor)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (am *ActorModel) Update(actor *Actor) error {
+// Update saves the actor only if the stored version still matches actor.Version, and
+// returns ErrEditConflict if someone else has changed the record in the meantime.
+func (am *ActorModel) Update(actor *Actor, userID int64) error {
     query := `
         UPDATE actors
-        SET full_name = $1, dorama_id = $2
-        WHERE id = $3
-        RETURNING id
+        SET full_name = $1, birth_date = NULLIF($2, '')::date, nationality = $3, biography = $4, agency = $5, links = $6,
+            version = version + 1
+        WHERE id = $7 AND version = $8 AND deleted_at IS NULL
+        RETURNING version
     `
-    args := []interface{}{actor.Name, actor.DoramaID, actor.ActorId}
+	if actor.Links == nil {
+		actor.Links = []Link{}
+	}
+	links, err := json.Marshal(actor.Links)
+	if err != nil {
+		return err
+	}
+    args := []interface{}{actor.Name, actor.BirthDate, actor.Nationality, actor.Biography, actor.Agency, links, actor.ActorId, actor.Version}
     ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
     defer cancel()
 
-    return am.DB.QueryRowContext(ctx, query, args...).Scan(&actor.ActorId)
+	tx, err := am.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := am.get(ctx, tx, actor.ActorId)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&actor.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, "actor", actor.ActorId, actor.Version, RevisionUpdate, userID, before, actor)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (am *ActorModel) Delete(id int) error {
+// Delete moves the actor to the trash, provided it is still at the given version. It
+// returns ErrEditConflict otherwise.
+func (am *ActorModel) Delete(id int, version int, userID int64) error {
 	query := `
-	 DELETE FROM actors 
-	 WHERE id = $1
+        UPDATE actors
+        SET deleted_at = NOW(), version = version + 1
+        WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+        RETURNING version
 	`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := am.DB.ExecContext(ctx, query, id)
-	return err
-} 
+	tx, err := am.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := am.get(ctx, tx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	var newVersion int
+	err = tx.QueryRowContext(ctx, query, id, version).Scan(&newVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, "actor", id, newVersion, RevisionDelete, userID, before, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore takes an actor back out of the trash.
+func (am *ActorModel) Restore(id int, userID int64) error {
+	query := `
+		UPDATE actors
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := am.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	after, err := am.get(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "actor", id, after.Version, RevisionRestore, userID, nil, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetPhoto records the URLs of a newly uploaded photo and its thumbnail, or clears
+// them when both are empty. Like DoramaModel.SetPoster, it leaves the version alone.
+func (am *ActorModel) SetPhoto(id int, url, thumbnailURL string) error {
+	query := `
+		UPDATE actors
+		SET photo_url = $1, photo_thumbnail_url = $2
+		WHERE id = $3 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := am.DB.ExecContext(ctx, query, url, thumbnailURL, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
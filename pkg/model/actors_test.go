@@ -0,0 +1,40 @@
+package model
+
+import "testing"
+
+func TestActorsFilterByDorama(t *testing.T) {
+	models := newTestModels(t)
+
+	dorama := insertTestDorama(t, models, &Dorama{})
+	other := insertTestDorama(t, models, &Dorama{})
+	actor := insertTestActor(t, models)
+
+	err := models.Doramas.SetCredit(&Credit{DoramaID: dorama.DoramaId, ActorID: actor.ActorId, Role: "actor"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}
+
+	tests := []struct {
+		name     string
+		doramaID int
+		want     int
+	}{
+		{"no filter", 0, 1},
+		{"credited", dorama.DoramaId, 1},
+		{"not credited", other.DoramaId, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actors, _, err := models.Actors.GetAll(actor.Name, tt.doramaID, "", filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(actors) != tt.want {
+				t.Errorf("got %d actors; want %d", len(actors), tt.want)
+			}
+		})
+	}
+}
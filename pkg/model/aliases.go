@@ -0,0 +1,170 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrDuplicateAlias = errors.New("duplicate alias")
+)
+
+// AliasKinds lists the kinds of alternative title a dorama can have.
+var AliasKinds = []string{"original", "romanized", "marketing", "other"}
+
+// Alias is an alternative title a dorama is known by, such as its name in the original
+// script, a romanization of it, or a marketing title. Language is optional.
+type Alias struct {
+	ID       int    `json:"id"`
+	DoramaID int    `json:"dorama_id"`
+	Alias    string `json:"alias"`
+	Kind     string `json:"kind"`
+	Language string `json:"language,omitempty"`
+}
+
+func ValidateAlias(v *validator.Validator, alias *Alias) {
+	v.Check(alias.Alias != "", "alias", "must be provided")
+	v.Check(len(alias.Alias) <= 255, "alias", "must not be more than 255 bytes long")
+	v.Check(validator.In(alias.Kind, AliasKinds...), "kind", "must be one of original, romanized, marketing or other")
+	v.Check(alias.Language == "" || validator.Matches(alias.Language, LanguageRX), "language", "must be a valid language tag")
+}
+
+type AliasModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+func (m AliasModel) GetAllForDorama(doramaID int) ([]*Alias, error) {
+	query := `
+		SELECT id, dorama_id, alias, kind, language
+		FROM dorama_aliases
+		WHERE dorama_id = $1
+		ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, doramaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []*Alias{}
+	for rows.Next() {
+		var alias Alias
+		err := rows.Scan(&alias.ID, &alias.DoramaID, &alias.Alias, &alias.Kind, &alias.Language)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, &alias)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+func (m AliasModel) Get(doramaID, id int) (*Alias, error) {
+	query := `
+		SELECT id, dorama_id, alias, kind, language
+		FROM dorama_aliases
+		WHERE dorama_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	alias := &Alias{}
+	err := m.DB.QueryRowContext(ctx, query, doramaID, id).Scan(&alias.ID, &alias.DoramaID, &alias.Alias, &alias.Kind, &alias.Language)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return alias, nil
+}
+
+func (m AliasModel) Insert(alias *Alias) error {
+	query := `
+		INSERT INTO dorama_aliases (dorama_id, alias, kind, language)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`
+
+	args := []interface{}{alias.DoramaID, alias.Alias, alias.Kind, alias.Language}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&alias.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateAlias
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m AliasModel) Update(alias *Alias) error {
+	query := `
+		UPDATE dorama_aliases
+		SET alias = $1, kind = $2, language = $3
+		WHERE id = $4 AND dorama_id = $5
+		RETURNING id`
+
+	args := []interface{}{alias.Alias, alias.Kind, alias.Language, alias.ID, alias.DoramaID}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&alias.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateAlias
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m AliasModel) Delete(doramaID, id int) error {
+	query := `
+		DELETE FROM dorama_aliases
+		WHERE dorama_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, doramaID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
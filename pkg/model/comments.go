@@ -0,0 +1,276 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// Comment is a reply to a review, or to another comment on it when ParentID is set.
+// Deleted marks a comment its author has withdrawn while others still reply to it; its
+// body is blank. Deleted and Replies are filled in on reads and ignored on writes.
+type Comment struct {
+	ID        int64      `json:"id"`
+	ReviewID  int64      `json:"review_id"`
+	ParentID  int64      `json:"parent_id,omitempty"`
+	UserID    int64      `json:"user_id"`
+	UserName  string     `json:"user_name"`
+	Body      string     `json:"body"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Version   int        `json:"version"`
+	Replies   []*Comment `json:"replies,omitempty"`
+}
+
+func ValidateComment(v *validator.Validator, comment *Comment) {
+	v.Check(comment.Body != "", "body", "must be provided")
+	v.Check(len(comment.Body) <= 5000, "body", "must not be more than 5000 bytes long")
+}
+
+type CommentModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// commentColumns are selected by every comment query, with the names the sort safelist
+// refers to.
+const commentColumns = `
+	review_comments.id, review_comments.review_id, COALESCE(review_comments.parent_id, 0), review_comments.user_id,
+	users.name, review_comments.body, review_comments.deleted_at IS NOT NULL, review_comments.created_at AS created_at,
+	review_comments.updated_at AS updated_at, review_comments.version`
+
+// GetAllForReview returns a page of the top-level comments on a review, each with its
+// whole thread of replies in Replies, oldest first. Hidden comments are left out along
+// with every reply below them.
+func (m CommentModel) GetAllForReview(reviewID int64, filters Filters) ([]*Comment, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM review_comments
+		INNER JOIN users ON users.id = review_comments.user_id
+		WHERE review_comments.review_id = $1 AND review_comments.parent_id IS NULL AND review_comments.hidden_at IS NULL
+		ORDER BY %s %s, id
+		LIMIT $2 OFFSET $3`,
+		commentColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, reviewID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	comments := []*Comment{}
+	for rows.Next() {
+		var comment Comment
+		err := rows.Scan(&totalRecords, &comment.ID, &comment.ReviewID, &comment.ParentID, &comment.UserID,
+			&comment.UserName, &comment.Body, &comment.Deleted, &comment.CreatedAt, &comment.UpdatedAt, &comment.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		comments = append(comments, &comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	err = m.fillReplies(ctx, comments)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return comments, metadata, nil
+}
+
+// fillReplies loads every visible reply below the given comments and hangs each one
+// under its parent.
+func (m CommentModel) fillReplies(ctx context.Context, roots []*Comment) error {
+	if len(roots) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Comment)
+	ids := make([]int64, 0, len(roots))
+	for _, root := range roots {
+		byID[root.ID] = root
+		ids = append(ids, root.ID)
+	}
+
+	query := fmt.Sprintf(`
+		WITH RECURSIVE thread AS (
+			SELECT id FROM review_comments WHERE parent_id = ANY($1) AND hidden_at IS NULL
+			UNION ALL
+			SELECT review_comments.id
+			FROM review_comments
+			INNER JOIN thread ON review_comments.parent_id = thread.id
+			WHERE review_comments.hidden_at IS NULL
+		)
+		SELECT %s
+		FROM review_comments
+		INNER JOIN users ON users.id = review_comments.user_id
+		WHERE review_comments.id IN (SELECT id FROM thread)
+		ORDER BY created_at, id`,
+		commentColumns)
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	replies := []*Comment{}
+	for rows.Next() {
+		var reply Comment
+		err := rows.Scan(&reply.ID, &reply.ReviewID, &reply.ParentID, &reply.UserID,
+			&reply.UserName, &reply.Body, &reply.Deleted, &reply.CreatedAt, &reply.UpdatedAt, &reply.Version)
+		if err != nil {
+			return err
+		}
+		byID[reply.ID] = &reply
+		replies = append(replies, &reply)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, reply := range replies {
+		if parent, ok := byID[reply.ParentID]; ok {
+			parent.Replies = append(parent.Replies, reply)
+		}
+	}
+	return nil
+}
+
+// Get returns a comment on a review, without its replies. Hidden and deleted comments
+// are reported as not found.
+func (m CommentModel) Get(reviewID, id int64) (*Comment, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM review_comments
+		INNER JOIN users ON users.id = review_comments.user_id
+		WHERE review_comments.review_id = $1 AND review_comments.id = $2
+			AND review_comments.hidden_at IS NULL AND review_comments.deleted_at IS NULL`,
+		commentColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	comment := &Comment{}
+	err := m.DB.QueryRowContext(ctx, query, reviewID, id).Scan(&comment.ID, &comment.ReviewID, &comment.ParentID, &comment.UserID,
+		&comment.UserName, &comment.Body, &comment.Deleted, &comment.CreatedAt, &comment.UpdatedAt, &comment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return comment, nil
+}
+
+// Insert adds a comment. The caller checks that the parent, if any, is a visible
+// comment on the same review.
+func (m CommentModel) Insert(comment *Comment) error {
+	query := `
+		INSERT INTO review_comments (review_id, parent_id, user_id, body)
+		VALUES ($1, NULLIF($2, 0), $3, $4)
+		RETURNING id, created_at, updated_at, version`
+
+	args := []interface{}{comment.ReviewID, comment.ParentID, comment.UserID, comment.Body}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&comment.ID, &comment.CreatedAt, &comment.UpdatedAt, &comment.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Update saves the comment only if the stored version still matches comment.Version,
+// and returns ErrEditConflict otherwise.
+func (m CommentModel) Update(comment *Comment) error {
+	query := `
+		UPDATE review_comments
+		SET body = $1, updated_at = NOW(), version = version + 1
+		WHERE id = $2 AND version = $3
+		RETURNING updated_at, version`
+
+	args := []interface{}{comment.Body, comment.ID, comment.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&comment.UpdatedAt, &comment.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete withdraws a comment. A comment without replies is removed; one with replies
+// keeps its place in the thread with its body blanked, so the replies below it stay.
+func (m CommentModel) Delete(id int64) error {
+	query := `
+		DELETE FROM review_comments
+		WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM review_comments AS replies WHERE replies.parent_id = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 1 {
+		return nil
+	}
+
+	query = `
+		UPDATE review_comments
+		SET body = '', deleted_at = NOW(), updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err = m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err = result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrUnknownCompany = errors.New("unknown company")
+)
+
+// Company is a broadcast network (Kind "network") or a production studio (Kind
+// "studio") that doramas can be associated with.
+type Company struct {
+	CompanyID int    `json:"company_id"`
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Version   int    `json:"version"`
+}
+
+func ValidateCompany(v *validator.Validator, company *Company) {
+	v.Check(company.Name != "", "name", "must be provided")
+	v.Check(len(company.Name) <= 255, "name", "must not be more than 255 bytes long")
+	v.Check(validator.In(company.Kind, "network", "studio"), "kind", "must be either network or studio")
+}
+
+type CompanyModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// GetAll returns a page of companies, optionally filtered by name and kind.
+func (m CompanyModel) GetAll(name string, kind string, filters Filters) ([]*Company, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), company_id, name, kind, version
+		FROM companies
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		AND (kind = $2 OR $2 = '')
+		ORDER BY %s %s, company_id
+		LIMIT $3 OFFSET $4`,
+		filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, kind, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	companies := []*Company{}
+	for rows.Next() {
+		var company Company
+		err := rows.Scan(&totalRecords, &company.CompanyID, &company.Name, &company.Kind, &company.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		companies = append(companies, &company)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return companies, metadata, nil
+}
+
+func (m CompanyModel) Get(id int) (*Company, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.get(ctx, m.DB, id)
+}
+
+func (m CompanyModel) get(ctx context.Context, q queryer, id int) (*Company, error) {
+	query := `
+		SELECT company_id, name, kind, version
+		FROM companies
+		WHERE company_id = $1`
+
+	company := &Company{}
+	err := q.QueryRowContext(ctx, query, id).Scan(&company.CompanyID, &company.Name, &company.Kind, &company.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return company, nil
+}
+
+// Insert adds a new company and records the change as made by userID.
+func (m CompanyModel) Insert(company *Company, userID int64) error {
+	query := `
+		INSERT INTO companies (name, kind)
+		VALUES ($1, $2)
+		RETURNING company_id, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, company.Name, company.Kind).Scan(&company.CompanyID, &company.Version)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "company", company.CompanyID, company.Version, RevisionInsert, userID, nil, company)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves the company only if the stored version still matches company.Version,
+// and returns ErrEditConflict otherwise.
+func (m CompanyModel) Update(company *Company, userID int64) error {
+	query := `
+		UPDATE companies
+		SET name = $1, kind = $2, version = version + 1
+		WHERE company_id = $3 AND version = $4
+		RETURNING version`
+
+	args := []interface{}{company.Name, company.Kind, company.CompanyID, company.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := m.get(ctx, tx, company.CompanyID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&company.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, "company", company.CompanyID, company.Version, RevisionUpdate, userID, before, company)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the company, provided it is still at the given version, together with
+// its links to doramas. It returns ErrEditConflict otherwise.
+func (m CompanyModel) Delete(id int, version int, userID int64) error {
+	query := `
+		DELETE FROM companies
+		WHERE company_id = $1 AND version = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := m.get(ctx, tx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	err = recordRevision(ctx, tx, "company", id, version+1, RevisionDelete, userID, before, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setDoramaCompanies replaces the company associations of a dorama inside an existing
+// transaction. Any ID that isn't a known company is reported as ErrUnknownCompany.
+func setDoramaCompanies(ctx context.Context, tx *sql.Tx, doramaID int, companyIDs []int64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM doramas_companies WHERE dorama_id = $1`, doramaID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO doramas_companies (dorama_id, company_id)
+		SELECT $1, company_id FROM companies
+		WHERE company_id = ANY($2)`
+
+	result, err := tx.ExecContext(ctx, query, doramaID, pq.Array(companyIDs))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != int64(len(companyIDs)) {
+		return ErrUnknownCompany
+	}
+	return nil
+}
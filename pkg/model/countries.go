@@ -0,0 +1,271 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrUnknownCountry   = errors.New("unknown country")
+	ErrDuplicateCountry = errors.New("duplicate country")
+)
+
+// CountryCodeRX matches an ISO 3166-1 alpha-2 country code, such as "KR" or "CN".
+var CountryCodeRX = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// Country is a country of origin that doramas can be associated with. Doramas refer
+// to countries by their code.
+type Country struct {
+	CountryID int    `json:"country_id"`
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Version   int    `json:"version"`
+}
+
+func ValidateCountry(v *validator.Validator, country *Country) {
+	v.Check(validator.Matches(country.Code, CountryCodeRX), "code", "must be a two-letter uppercase ISO 3166-1 code")
+	v.Check(country.Name != "", "name", "must be provided")
+	v.Check(len(country.Name) <= 255, "name", "must not be more than 255 bytes long")
+}
+
+type CountryModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+func (m CountryModel) GetAll(name string, filters Filters) ([]*Country, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), country_id, code, name, version
+		FROM countries
+		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		ORDER BY %s %s, country_id
+		LIMIT $2 OFFSET $3`,
+		filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	countries := []*Country{}
+	for rows.Next() {
+		var country Country
+		err := rows.Scan(&totalRecords, &country.CountryID, &country.Code, &country.Name, &country.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		countries = append(countries, &country)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return countries, metadata, nil
+}
+
+func (m CountryModel) Get(id int) (*Country, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.get(ctx, m.DB, id)
+}
+
+func (m CountryModel) get(ctx context.Context, q queryer, id int) (*Country, error) {
+	query := `
+		SELECT country_id, code, name, version
+		FROM countries
+		WHERE country_id = $1`
+
+	country := &Country{}
+	err := q.QueryRowContext(ctx, query, id).Scan(&country.CountryID, &country.Code, &country.Name, &country.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return country, nil
+}
+
+// Insert adds a new country and records the change as made by userID. It returns
+// ErrDuplicateCountry if the code is already taken.
+func (m CountryModel) Insert(country *Country, userID int64) error {
+	query := `
+		INSERT INTO countries (code, name)
+		VALUES ($1, $2)
+		RETURNING country_id, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, country.Code, country.Name).Scan(&country.CountryID, &country.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateCountry
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, "country", country.CountryID, country.Version, RevisionInsert, userID, nil, country)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves the country only if the stored version still matches country.Version,
+// and returns ErrEditConflict otherwise.
+func (m CountryModel) Update(country *Country, userID int64) error {
+	query := `
+		UPDATE countries
+		SET code = $1, name = $2, version = version + 1
+		WHERE country_id = $3 AND version = $4
+		RETURNING version`
+
+	args := []interface{}{country.Code, country.Name, country.CountryID, country.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := m.get(ctx, tx, country.CountryID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&country.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateCountry
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, "country", country.CountryID, country.Version, RevisionUpdate, userID, before, country)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the country, provided it is still at the given version, together with
+// its links to doramas. It returns ErrEditConflict otherwise.
+func (m CountryModel) Delete(id int, version int, userID int64) error {
+	query := `
+		DELETE FROM countries
+		WHERE country_id = $1 AND version = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := m.get(ctx, tx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	err = recordRevision(ctx, tx, "country", id, version+1, RevisionDelete, userID, before, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setDoramaCountries replaces the countries of origin of a dorama inside an existing
+// transaction. Any code that isn't a known country is reported as ErrUnknownCountry.
+func setDoramaCountries(ctx context.Context, tx *sql.Tx, doramaID int, codes []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM doramas_countries WHERE dorama_id = $1`, doramaID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO doramas_countries (dorama_id, country_id)
+		SELECT $1, country_id FROM countries
+		WHERE code = ANY($2)`
+
+	result, err := tx.ExecContext(ctx, query, doramaID, pq.Array(codes))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != int64(len(codes)) {
+		return ErrUnknownCountry
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// Credit roles. Despite the table and model names, a person in the actors table can be
+// credited in any of these.
+const (
+	RoleActor        = "actor"
+	RoleDirector     = "director"
+	RoleScreenwriter = "screenwriter"
+	RoleComposer     = "composer"
+	RoleProducer     = "producer"
+)
+
+var ErrUnknownActor = errors.New("unknown actor")
+
+var CreditRoles = []string{RoleActor, RoleDirector, RoleScreenwriter, RoleComposer, RoleProducer}
+
+// Credit links a person to a dorama in one role, together with the character they play
+// if they are an actor. The same struct is used for both directions of the
+// relationship: a dorama's credits fill in ActorName, while a person's filmography
+// fills in DoramaTitle and ReleaseYear.
+type Credit struct {
+	DoramaID      int    `json:"dorama_id"`
+	ActorID       int    `json:"actor_id"`
+	Role          string `json:"role"`
+	ActorName     string `json:"full_name,omitempty"`
+	DoramaTitle   string `json:"title,omitempty"`
+	ReleaseYear   int    `json:"release_year,omitempty"`
+	CharacterName string `json:"character_name,omitempty"`
+	BillingOrder  int    `json:"billing_order"`
+	IsLead        bool   `json:"is_lead"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(validator.In(credit.Role, CreditRoles...), "role", "must be one of actor, director, screenwriter, composer or producer")
+	v.Check(len(credit.CharacterName) <= 255, "character_name", "must not be more than 255 bytes long")
+	v.Check(credit.CharacterName == "" || credit.Role == RoleActor, "character_name", "must only be set for actors")
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must not be negative")
+}
+
+// GetCredits returns everyone credited in a dorama in the given role, or in any role if
+// role is empty. Credits are grouped by role, leads first and then in billing order.
+func (dm *DoramaModel) GetCredits(doramaID int, role string) ([]*Credit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return getCredits(ctx, dm.DB, doramaID, role)
+}
+
+// getCredits looks up a dorama's credits through q, which is either the connection pool
+// or a transaction that is changing them.
+func getCredits(ctx context.Context, q queryer, doramaID int, role string) ([]*Credit, error) {
+	query := `
+		SELECT doramas_actors.dorama_id, doramas_actors.actor_id, doramas_actors.role, actors.full_name,
+			doramas_actors.character_name, doramas_actors.billing_order, doramas_actors.is_lead
+		FROM doramas_actors
+		INNER JOIN actors ON actors.id = doramas_actors.actor_id
+		WHERE doramas_actors.dorama_id = $1 AND actors.deleted_at IS NULL
+		AND (doramas_actors.role = $2 OR $2 = '')
+		ORDER BY array_position($3, doramas_actors.role), doramas_actors.is_lead DESC, doramas_actors.billing_order, actors.full_name`
+
+	rows, err := q.QueryContext(ctx, query, doramaID, role, pq.Array(CreditRoles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*Credit{}
+	for rows.Next() {
+		var credit Credit
+		err := rows.Scan(&credit.DoramaID, &credit.ActorID, &credit.Role, &credit.ActorName, &credit.CharacterName, &credit.BillingOrder, &credit.IsLead)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return credits, nil
+}
+
+// SetCredit credits a person in a dorama, or updates the credit if they already hold
+// that role in it. The cast and crew are part of the dorama's history, so the change
+// bumps the dorama's version and is recorded as a revision made by userID. It returns
+// ErrRecordNotFound if the dorama or the person isn't live.
+func (dm *DoramaModel) SetCredit(credit *Credit, userID int64) error {
+	return dm.changeCredits(credit.DoramaID, userID, func(ctx context.Context, tx *sql.Tx) error {
+		query := `
+			INSERT INTO doramas_actors (dorama_id, actor_id, role, character_name, billing_order, is_lead)
+			SELECT $1, id, $3, $4, $5, $6 FROM actors
+			WHERE id = $2 AND deleted_at IS NULL
+			ON CONFLICT (dorama_id, actor_id, role)
+			DO UPDATE SET character_name = EXCLUDED.character_name, billing_order = EXCLUDED.billing_order, is_lead = EXCLUDED.is_lead`
+
+		args := []interface{}{credit.DoramaID, credit.ActorID, credit.Role, credit.CharacterName, credit.BillingOrder, credit.IsLead}
+		return execOne(ctx, tx, query, args...)
+	})
+}
+
+// RemoveCredit removes a person's credit in the given role from a dorama, and records
+// the change like SetCredit. It returns ErrRecordNotFound if they weren't credited that
+// way in the first place.
+func (dm *DoramaModel) RemoveCredit(doramaID, actorID int, role string, userID int64) error {
+	return dm.changeCredits(doramaID, userID, func(ctx context.Context, tx *sql.Tx) error {
+		query := `
+			DELETE FROM doramas_actors
+			WHERE dorama_id = $1 AND actor_id = $2 AND role = $3`
+
+		return execOne(ctx, tx, query, doramaID, actorID, role)
+	})
+}
+
+// changeCredits runs change inside a transaction that also bumps the dorama's version
+// and records the revision, so that the history covers every change to the cast.
+func (dm *DoramaModel) changeCredits(doramaID int, userID int64, change func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := dm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, before, err := dm.snapshot(ctx, tx, doramaID)
+	if err != nil {
+		return err
+	}
+
+	err = change(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE doramas
+		SET version = version + 1
+		WHERE dorama_id = $1
+		RETURNING version`
+
+	var version int
+	err = tx.QueryRowContext(ctx, query, doramaID).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	_, after, err := dm.snapshot(ctx, tx, doramaID)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "dorama", doramaID, version, RevisionUpdate, userID, before, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// setDoramaCredits replaces the credits of a dorama with the given ones as part of tx.
+// Credits of trashed people are kept, since they reappear once the person is restored.
+// Any credit of a person who isn't live is reported as ErrUnknownActor.
+func setDoramaCredits(ctx context.Context, tx *sql.Tx, doramaID int, credits []*Credit) error {
+	query := `
+		DELETE FROM doramas_actors
+		WHERE dorama_id = $1
+		AND actor_id NOT IN (SELECT id FROM actors WHERE deleted_at IS NOT NULL)`
+
+	_, err := tx.ExecContext(ctx, query, doramaID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO doramas_actors (dorama_id, actor_id, role, character_name, billing_order, is_lead)
+		SELECT $1, id, $3, $4, $5, $6 FROM actors
+		WHERE id = $2 AND deleted_at IS NULL
+		ON CONFLICT (dorama_id, actor_id, role)
+		DO UPDATE SET character_name = EXCLUDED.character_name, billing_order = EXCLUDED.billing_order, is_lead = EXCLUDED.is_lead`
+
+	for _, credit := range credits {
+		err = execOne(ctx, tx, query, doramaID, credit.ActorID, credit.Role, credit.CharacterName, credit.BillingOrder, credit.IsLead)
+		if err != nil {
+			if errors.Is(err, ErrRecordNotFound) {
+				return ErrUnknownActor
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// execOne runs a statement that must affect at least one row, and returns
+// ErrRecordNotFound if it didn't.
+func execOne(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) error {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetFilmography returns every credit a person has in the given role, or in any role
+// if role is empty, newest dorama first. Doramas rated above maturityLevel are left out.
+func (am *ActorModel) GetFilmography(actorID int, role string, maturityLevel int) ([]*Credit, error) {
+	query := `
+		SELECT doramas_actors.dorama_id, doramas_actors.actor_id, doramas_actors.role, doramas.title, COALESCE(doramas.release_year, 0),
+			doramas_actors.character_name, doramas_actors.billing_order, doramas_actors.is_lead
+		FROM doramas_actors
+		INNER JOIN doramas ON doramas.dorama_id = doramas_actors.dorama_id
+		WHERE doramas_actors.actor_id = $1 AND doramas.deleted_at IS NULL
+		AND doramas.min_age <= $3
+		AND (doramas_actors.role = $2 OR $2 = '')
+		ORDER BY doramas.release_year DESC NULLS LAST, doramas.title, doramas_actors.role`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := am.DB.QueryContext(ctx, query, actorID, role, maturityLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	filmography := []*Credit{}
+	for rows.Next() {
+		var credit Credit
+		err := rows.Scan(&credit.DoramaID, &credit.ActorID, &credit.Role, &credit.DoramaTitle, &credit.ReleaseYear, &credit.CharacterName, &credit.BillingOrder, &credit.IsLead)
+		if err != nil {
+			return nil, err
+		}
+		filmography = append(filmography, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return filmography, nil
+}
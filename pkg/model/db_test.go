@@ -0,0 +1,114 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// newTestModels connects to the database named by MCA_TEST_DB_DSN, which must be a
+// disposable database with every migration applied. Tests that need a database are
+// skipped when the variable isn't set.
+func newTestModels(t *testing.T) Models {
+	t.Helper()
+
+	dsn := os.Getenv("MCA_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("MCA_TEST_DB_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewModels(db)
+}
+
+// insertTestDorama adds a dorama, filling in a unique title and the announced status
+// unless they are set, and removes it again, along with everything hanging off it, once
+// the test is over.
+func insertTestDorama(t *testing.T, models Models, dorama *Dorama) *Dorama {
+	t.Helper()
+
+	if dorama.Title == "" {
+		dorama.Title = fmt.Sprintf("Test dorama %d", time.Now().UnixNano())
+	}
+	if dorama.Status == "" {
+		dorama.Status = StatusAnnounced
+	}
+
+	err := models.Doramas.Insert(dorama, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_, err := models.Doramas.DB.Exec(`DELETE FROM doramas WHERE dorama_id = $1`, dorama.DoramaId)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	return dorama
+}
+
+// insertTestActor adds a person and removes them again once the test is over.
+func insertTestActor(t *testing.T, models Models) *Actor {
+	t.Helper()
+
+	actor := &Actor{Name: fmt.Sprintf("Test actor %d", time.Now().UnixNano())}
+	err := models.Actors.Insert(actor, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_, err := models.Actors.DB.Exec(`DELETE FROM actors WHERE id = $1`, actor.ActorId)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	return actor
+}
+
+// insertTestUser adds an activated user with the given maturity level, and removes
+// them again, along with their tokens and watchlist, once the test is over.
+func insertTestUser(t *testing.T, models Models, maturityLevel int) *User {
+	t.Helper()
+
+	user := &User{
+		Name:          "Test user",
+		Email:         fmt.Sprintf("test-%d@example.com", time.Now().UnixNano()),
+		Activated:     true,
+		MaturityLevel: maturityLevel,
+	}
+	// A cheap hash is all a test needs; the real cost is set by password.Set.
+	user.Password.hash = []byte("not a real hash")
+
+	err := models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_, err := models.Users.DB.Exec(`DELETE FROM users WHERE id = $1`, user.ID)
+		if err != nil {
+			t.Error(err)
+		}
+	})
+	return user
+}
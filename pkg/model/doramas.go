@@ -7,16 +7,77 @@ import (
 	"time"
 	"log"
 	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// Define constants for the stages of a dorama's airing lifecycle.
+const (
+	StatusAnnounced = "announced"
+	StatusAiring    = "airing"
+	StatusFinished  = "finished"
+	StatusCancelled = "cancelled"
 )
 
 type Dorama struct {
-	DoramaId    int    `json:"dorama_id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	ReleaseYear int    `json:"release_year"`
-	Duration    int    `json:"duration"`
-	MainActors  string `json:"main_actors"`
-	GenreId     int    `json:"genre_id"`
+	DoramaId    int     `json:"dorama_id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	ReleaseYear int     `json:"release_year"`
+	Duration    int     `json:"duration"`
+	Status      string  `json:"status"`
+	StartDate   string  `json:"start_date,omitempty"`
+	EndDate     string  `json:"end_date,omitempty"`
+	// The poster is uploaded separately, and these are ignored on insert and update.
+	PosterURL          string `json:"poster_url,omitempty"`
+	PosterThumbnailURL string `json:"poster_thumbnail_url,omitempty"`
+	GenreIDs    []int64 `json:"genre_ids"`
+	CompanyIDs  []int64 `json:"company_ids"`
+	// Countries of origin, as ISO 3166-1 alpha-2 codes.
+	CountryCodes []string `json:"country_codes"`
+	RatingSystem    string   `json:"rating_system,omitempty"`
+	ContentRating   string   `json:"content_rating,omitempty"`
+	// MinAge is derived from the rating and is ignored on insert and update.
+	MinAge          int      `json:"min_age"`
+	ContentWarnings []string `json:"content_warnings"`
+	// EpisodeCount and TotalRuntime are derived from the episodes table and are
+	// ignored on insert and update.
+	EpisodeCount int    `json:"episode_count"`
+	TotalRuntime int    `json:"total_runtime"`
+	// Rating is the average review score, or 0 when there are no reviews yet, and
+	// RatingCount the number of reviews. Reviews hidden by a moderator don't count.
+	// Both are ignored on insert and update.
+	Rating       float64 `json:"rating"`
+	RatingCount  int     `json:"rating_count"`
+	Version      int    `json:"version"`
+	// Language is the language the title and description were localized into, and is
+	// left empty when they are shown as stored.
+	Language     string `json:"language,omitempty"`
+	// MatchedAlias is set by GetAll when the title filter matched one of the dorama's
+	// aliases, so that clients can show why a result came up.
+	MatchedAlias string `json:"matched_alias,omitempty"`
+}
+
+func ValidateDorama(v *validator.Validator, dorama *Dorama) {
+	v.Check(dorama.Title != "", "title", "must be provided")
+	v.Check(len(dorama.Title) <= 255, "title", "must not be more than 255 bytes long")
+	v.Check(dorama.ReleaseYear >= 0, "release_year", "must not be negative")
+	v.Check(dorama.Duration >= 0, "duration", "must not be negative")
+	v.Check(validator.In(dorama.Status, StatusAnnounced, StatusAiring, StatusFinished, StatusCancelled), "status", "must be one of announced, airing, finished or cancelled")
+	v.Check(dorama.StartDate == "" || validator.Date(dorama.StartDate), "start_date", "must be a date in YYYY-MM-DD format")
+	v.Check(dorama.EndDate == "" || validator.Date(dorama.EndDate), "end_date", "must be a date in YYYY-MM-DD format")
+	// Dates in YYYY-MM-DD format compare correctly as strings.
+	v.Check(dorama.EndDate == "" || dorama.StartDate != "", "end_date", "must not be set without a start date")
+	v.Check(dorama.EndDate == "" || dorama.EndDate >= dorama.StartDate, "end_date", "must not be before the start date")
+	v.Check(dorama.Status != StatusAiring || dorama.StartDate != "", "start_date", "must be provided for an airing dorama")
+	v.Check(validator.Unique(dorama.GenreIDs), "genre_ids", "must not contain duplicate values")
+	v.Check(validator.Unique(dorama.CompanyIDs), "company_ids", "must not contain duplicate values")
+	v.Check(validator.Unique(dorama.CountryCodes), "country_codes", "must not contain duplicate values")
+	for _, code := range dorama.CountryCodes {
+		v.Check(validator.Matches(code, CountryCodeRX), "country_codes", "must only contain two-letter uppercase ISO 3166-1 codes")
+	}
+	ValidateContentRating(v, dorama)
 }
 
 type DoramaModel struct {
@@ -25,16 +86,112 @@ type DoramaModel struct {
 	ErrorLog *log.Logger
 }
 
-func (m DoramaModel) GetAll(title string, releaseYear int,filters Filters) ([]*Dorama, Metadata, error) {
+// DoramaSnapshot is a dorama as its revisions record it: the fields an editor can change
+// together with its cast and crew. Derived fields such as the rating, the episode count
+// and the version, and the separately uploaded poster, are left out, so that a revert
+// never writes them back. Revisions from before the cast was recorded have nil Credits.
+type DoramaSnapshot struct {
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	ReleaseYear     int       `json:"release_year"`
+	Duration        int       `json:"duration"`
+	Status          string    `json:"status"`
+	StartDate       string    `json:"start_date,omitempty"`
+	EndDate         string    `json:"end_date,omitempty"`
+	GenreIDs        []int64   `json:"genre_ids"`
+	CompanyIDs      []int64   `json:"company_ids"`
+	CountryCodes    []string  `json:"country_codes"`
+	RatingSystem    string    `json:"rating_system,omitempty"`
+	ContentRating   string    `json:"content_rating,omitempty"`
+	ContentWarnings []string  `json:"content_warnings"`
+	Credits         []*Credit `json:"credits"`
+}
+
+// snapshot looks up a live dorama and its credits through q, and returns the dorama
+// together with its snapshot for a revision.
+func (dm *DoramaModel) snapshot(ctx context.Context, q queryer, id int) (*Dorama, *DoramaSnapshot, error) {
+	dorama, err := dm.get(ctx, q, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	credits, err := getCredits(ctx, q, id, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapshot := &DoramaSnapshot{
+		Title:           dorama.Title,
+		Description:     dorama.Description,
+		ReleaseYear:     dorama.ReleaseYear,
+		Duration:        dorama.Duration,
+		Status:          dorama.Status,
+		StartDate:       dorama.StartDate,
+		EndDate:         dorama.EndDate,
+		GenreIDs:        dorama.GenreIDs,
+		CompanyIDs:      dorama.CompanyIDs,
+		CountryCodes:    dorama.CountryCodes,
+		RatingSystem:    dorama.RatingSystem,
+		ContentRating:   dorama.ContentRating,
+		ContentWarnings: dorama.ContentWarnings,
+		Credits:         credits,
+	}
+	return dorama, snapshot, nil
+}
+
+// GetAll returns a page of doramas. If genreIDs is not empty, only doramas in at least
+// one of those genres are returned, or in every one of them when matchAllGenres is set.
+// Non-empty companyIDs and countryCodes likewise keep doramas linked to any of them, and
+// a non-empty status keeps only doramas at that stage of their lifecycle. Doramas rated
+// above maturityLevel are always left out.
+func (m DoramaModel) GetAll(title string, releaseYear int, status string, genreIDs []int64, matchAllGenres bool, companyIDs []int64, countryCodes []string, maturityLevel int, filters Filters) ([]*Dorama, Metadata, error) {
 	// Retrieve all doramas from the database.
 	query := fmt.Sprintf(
 		`
-		SELECT count(*) OVER(), dorama_id, title, description, release_year, duration, main_actors
+		SELECT count(*) OVER(), dorama_id, title, description, release_year, duration,
+			status, COALESCE(to_char(start_date, 'YYYY-MM-DD'), ''), COALESCE(to_char(end_date, 'YYYY-MM-DD'), ''), poster_url, poster_thumbnail_url,
+			ARRAY(SELECT genre_id FROM doramas_genres INNER JOIN genres USING (genre_id) WHERE doramas_genres.dorama_id = doramas.dorama_id AND genres.deleted_at IS NULL ORDER BY genre_id),
+			ARRAY(SELECT company_id FROM doramas_companies WHERE doramas_companies.dorama_id = doramas.dorama_id ORDER BY company_id),
+			ARRAY(SELECT code FROM doramas_countries INNER JOIN countries USING (country_id) WHERE doramas_countries.dorama_id = doramas.dorama_id ORDER BY code),
+			rating_system, content_rating, min_age, content_warnings,
+			(SELECT count(*) FROM episodes WHERE episodes.dorama_id = doramas.dorama_id),
+			(SELECT COALESCE(sum(runtime), 0) FROM episodes WHERE episodes.dorama_id = doramas.dorama_id),
+			(SELECT COALESCE(round(avg(score), 1), 0) FROM reviews WHERE reviews.dorama_id = doramas.dorama_id AND reviews.hidden_at IS NULL) AS rating,
+			(SELECT count(*) FROM reviews WHERE reviews.dorama_id = doramas.dorama_id AND reviews.hidden_at IS NULL),
+			version,
+			COALESCE(matched_alias.alias, '')
 		FROM doramas
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		LEFT JOIN LATERAL (
+			SELECT alias FROM dorama_aliases
+			WHERE dorama_aliases.dorama_id = doramas.dorama_id AND $1 <> ''
+			AND to_tsvector('simple', alias) @@ plainto_tsquery('simple', $1)
+			ORDER BY id
+			LIMIT 1
+		) AS matched_alias ON true
+		WHERE deleted_at IS NULL
+		AND (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '' OR matched_alias.alias IS NOT NULL OR EXISTS (
+			SELECT 1 FROM dorama_translations
+			WHERE dorama_translations.dorama_id = doramas.dorama_id
+			AND to_tsvector('simple', dorama_translations.title) @@ plainto_tsquery('simple', $1)
+		))
 		AND (release_year = $2 OR $2 = 1)
+		AND (status = $9 OR $9 = '')
+		AND min_age <= $10
+		AND (cardinality($3::integer[]) = 0 OR (
+			SELECT count(*) FROM doramas_genres INNER JOIN genres USING (genre_id)
+			WHERE doramas_genres.dorama_id = doramas.dorama_id AND doramas_genres.genre_id = ANY($3)
+			AND genres.deleted_at IS NULL
+		) >= CASE WHEN $4 THEN cardinality($3::integer[]) ELSE 1 END)
+		AND (cardinality($7::integer[]) = 0 OR EXISTS (
+			SELECT 1 FROM doramas_companies
+			WHERE doramas_companies.dorama_id = doramas.dorama_id AND doramas_companies.company_id = ANY($7)
+		))
+		AND (cardinality($8::text[]) = 0 OR EXISTS (
+			SELECT 1 FROM doramas_countries INNER JOIN countries USING (country_id)
+			WHERE doramas_countries.dorama_id = doramas.dorama_id AND countries.code = ANY($8)
+		))
 		ORDER BY %s %s, dorama_id
-		LIMIT $3 OFFSET $4`,
+		LIMIT $5 OFFSET $6`,
 		filters.sortColumn(), filters.sortDirection())
 
 	// Create a context with a 3-second timeout.
@@ -42,7 +199,7 @@ func (m DoramaModel) GetAll(title string, releaseYear int,filters Filters) ([]*D
 	defer cancel()
 
 	// Organize our placeholder parameter values in a slice.
-	args := []interface{}{title, releaseYear,filters.limit(), filters.offset()}
+	args := []interface{}{title, releaseYear, pq.Array(genreIDs), matchAllGenres, filters.limit(), filters.offset(), pq.Array(companyIDs), pq.Array(countryCodes), status, maturityLevel}
 
 	// Use QueryContext to execute the query.
 	rows, err := m.DB.QueryContext(ctx, query, args...)
@@ -61,7 +218,7 @@ func (m DoramaModel) GetAll(title string, releaseYear int,filters Filters) ([]*D
 	var doramas []*Dorama
 	for rows.Next() {
 		var dorama Dorama
-		err := rows.Scan(&totalRecords, &dorama.DoramaId, &dorama.Title, &dorama.Description, &dorama.ReleaseYear, &dorama.Duration, &dorama.MainActors)
+		err := rows.Scan(&totalRecords, &dorama.DoramaId, &dorama.Title, &dorama.Description, &dorama.ReleaseYear, &dorama.Duration, &dorama.Status, &dorama.StartDate, &dorama.EndDate, &dorama.PosterURL, &dorama.PosterThumbnailURL, pq.Array(&dorama.GenreIDs), pq.Array(&dorama.CompanyIDs), pq.Array(&dorama.CountryCodes), &dorama.RatingSystem, &dorama.ContentRating, &dorama.MinAge, pq.Array(&dorama.ContentWarnings), &dorama.EpisodeCount, &dorama.TotalRuntime, &dorama.Rating, &dorama.RatingCount, &dorama.Version, &dorama.MatchedAlias)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
@@ -79,20 +236,67 @@ func (m DoramaModel) GetAll(title string, releaseYear int,filters Filters) ([]*D
 	return doramas, metadata, nil
 }
 
-func (dm *DoramaModel) Get(id int) (*Dorama, error) {
+// Get returns a live dorama, or ErrRecordNotFound if it is rated above maturityLevel,
+// so that titles a user shouldn't see look the same as titles that don't exist.
+func (dm *DoramaModel) Get(id int, maturityLevel int) (*Dorama, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	dorama, err := dm.get(ctx, dm.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if dorama.MinAge > maturityLevel {
+		return nil, ErrRecordNotFound
+	}
+	return dorama, nil
+}
+
+// GetMinAge returns the minimum age a dorama is rated for, whether it is live or in
+// the trash, or ErrRecordNotFound if there is no such dorama.
+func (dm *DoramaModel) GetMinAge(id int) (int, error) {
+	query := `SELECT min_age FROM doramas WHERE dorama_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var minAge int
+	err := dm.DB.QueryRowContext(ctx, query, id).Scan(&minAge)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+	return minAge, nil
+}
+
+// get looks up a live dorama through q, which is either the connection pool or a
+// transaction that is about to modify the same record.
+func (dm *DoramaModel) get(ctx context.Context, q queryer, id int) (*Dorama, error) {
 	query := `
-	SELECT dorama_id, title, description, release_year, duration, main_actors, genre_id
+	SELECT dorama_id, title, description, release_year, duration,
+		status, COALESCE(to_char(start_date, 'YYYY-MM-DD'), ''), COALESCE(to_char(end_date, 'YYYY-MM-DD'), ''), poster_url, poster_thumbnail_url,
+		ARRAY(SELECT genre_id FROM doramas_genres INNER JOIN genres USING (genre_id) WHERE doramas_genres.dorama_id = doramas.dorama_id AND genres.deleted_at IS NULL ORDER BY genre_id),
+		ARRAY(SELECT company_id FROM doramas_companies WHERE doramas_companies.dorama_id = doramas.dorama_id ORDER BY company_id),
+		ARRAY(SELECT code FROM doramas_countries INNER JOIN countries USING (country_id) WHERE doramas_countries.dorama_id = doramas.dorama_id ORDER BY code),
+		rating_system, content_rating, min_age, content_warnings,
+		(SELECT count(*) FROM episodes WHERE episodes.dorama_id = doramas.dorama_id),
+		(SELECT COALESCE(sum(runtime), 0) FROM episodes WHERE episodes.dorama_id = doramas.dorama_id),
+		(SELECT COALESCE(round(avg(score), 1), 0) FROM reviews WHERE reviews.dorama_id = doramas.dorama_id AND reviews.hidden_at IS NULL),
+		(SELECT count(*) FROM reviews WHERE reviews.dorama_id = doramas.dorama_id AND reviews.hidden_at IS NULL),
+		version
 	FROM doramas
-	WHERE dorama_id = $1
+	WHERE dorama_id = $1 AND deleted_at IS NULL
     `
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
 
 	dorama := &Dorama{}
-	err := dm.DB.QueryRowContext(ctx, query, id).Scan(&dorama.DoramaId, &dorama.Title, &dorama.Description, &dorama.ReleaseYear,&dorama.Duration, &dorama.MainActors, &dorama.GenreId)
+	err := q.QueryRowContext(ctx, query, id).Scan(&dorama.DoramaId, &dorama.Title, &dorama.Description, &dorama.ReleaseYear, &dorama.Duration, &dorama.Status, &dorama.StartDate, &dorama.EndDate, &dorama.PosterURL, &dorama.PosterThumbnailURL, pq.Array(&dorama.GenreIDs), pq.Array(&dorama.CompanyIDs), pq.Array(&dorama.CountryCodes), &dorama.RatingSystem, &dorama.ContentRating, &dorama.MinAge, pq.Array(&dorama.ContentWarnings), &dorama.EpisodeCount, &dorama.TotalRuntime, &dorama.Rating, &dorama.RatingCount, &dorama.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("film not found")
+			return nil, ErrRecordNotFound
 		} else {
 			return nil, err
 		}
@@ -101,43 +305,280 @@ func (dm *DoramaModel) Get(id int) (*Dorama, error) {
 	return dorama, nil
 }
 
-func (dm *DoramaModel) Insert(dorama *Dorama) error {
+// Insert adds a new dorama together with its genre, company and country associations,
+// and records the change as made by userID. All writes happen in a single transaction,
+// so a dorama is never left behind without its associations or its history.
+func (dm *DoramaModel) Insert(dorama *Dorama, userID int64) error {
 	query := `
-		INSERT INTO doramas (title, description, release_year, duration, main_actors, genre_id) 
-		VALUES ($1, $2, $3, $4, $5, $6) 
-		where dorama_id = $7
-		RETURNING dorama_id,title, description, release_year, duration, main_actors, genre_id
+		INSERT INTO doramas (title, description, release_year, duration, status, start_date, end_date,
+			rating_system, content_rating, min_age, content_warnings)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::date, NULLIF($7, '')::date, $8, $9, $10, $11)
+		RETURNING dorama_id
 		`
-	args := []interface{}{dorama.Title, dorama.Description, dorama.ReleaseYear, dorama.Duration ,dorama.MainActors, dorama.GenreId}
+	minAge, _ := MinAge(dorama.RatingSystem, dorama.ContentRating)
+	if dorama.ContentWarnings == nil {
+		dorama.ContentWarnings = []string{}
+	}
+	args := []interface{}{dorama.Title, dorama.Description, dorama.ReleaseYear, dorama.Duration, dorama.Status, dorama.StartDate, dorama.EndDate,
+		dorama.RatingSystem, dorama.ContentRating, minAge, pq.Array(dorama.ContentWarnings)}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return dm.DB.QueryRowContext(ctx, query, args...).Scan(&dorama.DoramaId)
+	tx, err := dm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&dorama.DoramaId)
+	if err != nil {
+		return err
+	}
+
+	err = setDoramaGenres(ctx, tx, dorama.DoramaId, dorama.GenreIDs)
+	if err != nil {
+		return err
+	}
+
+	err = setDoramaCompanies(ctx, tx, dorama.DoramaId, dorama.CompanyIDs)
+	if err != nil {
+		return err
+	}
+
+	err = setDoramaCountries(ctx, tx, dorama.DoramaId, dorama.CountryCodes)
+	if err != nil {
+		return err
+	}
+
+	stored, after, err := dm.snapshot(ctx, tx, dorama.DoramaId)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "dorama", stored.DoramaId, stored.Version, RevisionInsert, userID, nil, after)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	*dorama = *stored
+	return nil
+}
+
+// Update saves the dorama and replaces its genre, company and country associations. The
+// write only goes through if the stored version still matches dorama.Version, otherwise
+// ErrEditConflict is returned.
+func (dm *DoramaModel) Update(dorama *Dorama, userID int64) error {
+	return dm.update(dorama, nil, userID)
+}
+
+// Revert saves the dorama like Update, and also replaces its cast and crew with credits
+// unless credits is nil, all as a single revision. A credit of a person who is no longer
+// live is reported as ErrUnknownActor.
+func (dm *DoramaModel) Revert(dorama *Dorama, credits []*Credit, userID int64) error {
+	return dm.update(dorama, credits, userID)
 }
 
-func (dm *DoramaModel) Update(dorama *Dorama) error {
+func (dm *DoramaModel) update(dorama *Dorama, credits []*Credit, userID int64) error {
     query := `
         UPDATE doramas
-        SET title = $1, description = $2, release_year = $3, duration = $4, main_actors = $5, genre_id = $6
-        WHERE dorama_id = $7
-        RETURNING dorama_id
+        SET title = $1, description = $2, release_year = $3, duration = $4,
+            status = $5, start_date = NULLIF($6, '')::date, end_date = NULLIF($7, '')::date,
+            rating_system = $10, content_rating = $11, min_age = $12, content_warnings = $13, version = version + 1
+        WHERE dorama_id = $8 AND version = $9 AND deleted_at IS NULL
+        RETURNING version
     `
-    args := []interface{}{dorama.Title,dorama.Description,dorama.ReleaseYear,dorama.Duration,dorama.MainActors,dorama.GenreId,dorama.DoramaId}
+	minAge, _ := MinAge(dorama.RatingSystem, dorama.ContentRating)
+	if dorama.ContentWarnings == nil {
+		dorama.ContentWarnings = []string{}
+	}
+    args := []interface{}{dorama.Title,dorama.Description,dorama.ReleaseYear,dorama.Duration,dorama.Status,dorama.StartDate,dorama.EndDate,dorama.DoramaId,dorama.Version,
+		dorama.RatingSystem, dorama.ContentRating, minAge, pq.Array(dorama.ContentWarnings)}
     ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
     defer cancel()
-	
-    return dm.DB.QueryRowContext(ctx, query, args...).Scan(&dorama.DoramaId)
+
+	tx, err := dm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, before, err := dm.snapshot(ctx, tx, dorama.DoramaId)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&dorama.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = setDoramaGenres(ctx, tx, dorama.DoramaId, dorama.GenreIDs)
+	if err != nil {
+		return err
+	}
+
+	err = setDoramaCompanies(ctx, tx, dorama.DoramaId, dorama.CompanyIDs)
+	if err != nil {
+		return err
+	}
+
+	err = setDoramaCountries(ctx, tx, dorama.DoramaId, dorama.CountryCodes)
+	if err != nil {
+		return err
+	}
+
+	if credits != nil {
+		err = setDoramaCredits(ctx, tx, dorama.DoramaId, credits)
+		if err != nil {
+			return err
+		}
+	}
+
+	stored, after, err := dm.snapshot(ctx, tx, dorama.DoramaId)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "dorama", stored.DoramaId, stored.Version, RevisionUpdate, userID, before, after)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	*dorama = *stored
+	return nil
 }
 
-func (dm *DoramaModel) Delete(id int) error {
+// Delete moves the dorama to the trash, provided it is still at the given version. It
+// returns ErrEditConflict otherwise. Trashed doramas are hidden from Get and GetAll
+// until they are restored, or purged for good by TrashModel.Purge.
+func (dm *DoramaModel) Delete(id int, version int, userID int64) error {
 	query := `
-        DELETE FROM doramas
-        WHERE dorama_id = $1
+        UPDATE doramas
+        SET deleted_at = NOW(), version = version + 1
+        WHERE dorama_id = $1 AND version = $2 AND deleted_at IS NULL
+        RETURNING version
         `
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := dm.DB.ExecContext(ctx, query, id)
-	return err
+	tx, err := dm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, before, err := dm.snapshot(ctx, tx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	var newVersion int
+	err = tx.QueryRowContext(ctx, query, id, version).Scan(&newVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, "dorama", id, newVersion, RevisionDelete, userID, before, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore takes a dorama back out of the trash.
+func (dm *DoramaModel) Restore(id int, userID int64) error {
+	query := `
+		UPDATE doramas
+		SET deleted_at = NULL, version = version + 1
+		WHERE dorama_id = $1 AND deleted_at IS NOT NULL
+		RETURNING dorama_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := dm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	stored, after, err := dm.snapshot(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "dorama", id, stored.Version, RevisionRestore, userID, nil, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// SetPoster records the URLs of a newly uploaded poster and its thumbnail, or clears
+// them when both are empty. Artwork isn't part of the dorama's editable fields, so this
+// neither bumps the version nor records a revision.
+func (dm *DoramaModel) SetPoster(id int, url, thumbnailURL string) error {
+	query := `
+		UPDATE doramas
+		SET poster_url = $1, poster_thumbnail_url = $2
+		WHERE dorama_id = $3 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := dm.DB.ExecContext(ctx, query, url, thumbnailURL, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrDuplicateEpisode = errors.New("duplicate episode")
+	ErrUnknownSeason    = errors.New("unknown season")
+)
+
+// Episode is a single episode of a dorama. Every episode belongs to a season, so a
+// one-season show keeps its episodes in season 1. Runtime is in minutes.
+type Episode struct {
+	ID           int    `json:"id"`
+	DoramaID     int    `json:"dorama_id"`
+	SeasonNumber int    `json:"season_number"`
+	Number       int    `json:"episode_number"`
+	Title        string `json:"title"`
+	AirDate      string `json:"air_date,omitempty"`
+	Runtime      int    `json:"runtime"`
+	Synopsis     string `json:"synopsis"`
+}
+
+func ValidateEpisode(v *validator.Validator, episode *Episode) {
+	v.Check(episode.SeasonNumber > 0, "season_number", "must be greater than zero")
+	v.Check(episode.Number > 0, "episode_number", "must be greater than zero")
+	v.Check(len(episode.Title) <= 255, "title", "must not be more than 255 bytes long")
+	v.Check(episode.AirDate == "" || validator.Date(episode.AirDate), "air_date", "must be a date in YYYY-MM-DD format")
+	v.Check(episode.Runtime >= 0, "runtime", "must not be negative")
+}
+
+type EpisodeModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// GetAllForDorama returns the episodes of a dorama in airing order. Passing a season
+// number of 0 returns the episodes of every season.
+func (em *EpisodeModel) GetAllForDorama(doramaID, seasonNumber int) ([]*Episode, error) {
+	query := `
+		SELECT id, dorama_id, season_number, episode_number, title, COALESCE(to_char(air_date, 'YYYY-MM-DD'), ''), runtime, synopsis
+		FROM episodes
+		WHERE dorama_id = $1
+		AND (season_number = $2 OR $2 = 0)
+		ORDER BY season_number, episode_number`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := em.DB.QueryContext(ctx, query, doramaID, seasonNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	episodes := []*Episode{}
+	for rows.Next() {
+		var episode Episode
+		err := rows.Scan(&episode.ID, &episode.DoramaID, &episode.SeasonNumber, &episode.Number, &episode.Title, &episode.AirDate, &episode.Runtime, &episode.Synopsis)
+		if err != nil {
+			return nil, err
+		}
+		episodes = append(episodes, &episode)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+func (em *EpisodeModel) Get(doramaID, id int) (*Episode, error) {
+	query := `
+		SELECT id, dorama_id, season_number, episode_number, title, COALESCE(to_char(air_date, 'YYYY-MM-DD'), ''), runtime, synopsis
+		FROM episodes
+		WHERE dorama_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	episode := &Episode{}
+	err := em.DB.QueryRowContext(ctx, query, doramaID, id).Scan(&episode.ID, &episode.DoramaID, &episode.SeasonNumber, &episode.Number, &episode.Title, &episode.AirDate, &episode.Runtime, &episode.Synopsis)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return episode, nil
+}
+
+func (em *EpisodeModel) Insert(episode *Episode) error {
+	query := `
+		INSERT INTO episodes (dorama_id, season_number, episode_number, title, air_date, runtime, synopsis)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::date, $6, $7)
+		RETURNING id`
+
+	args := []interface{}{episode.DoramaID, episode.SeasonNumber, episode.Number, episode.Title, episode.AirDate, episode.Runtime, episode.Synopsis}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := em.DB.QueryRowContext(ctx, query, args...).Scan(&episode.ID)
+	if err != nil {
+		return episodeError(err)
+	}
+	return nil
+}
+
+func (em *EpisodeModel) Update(episode *Episode) error {
+	query := `
+		UPDATE episodes
+		SET season_number = $1, episode_number = $2, title = $3, air_date = NULLIF($4, '')::date, runtime = $5, synopsis = $6
+		WHERE dorama_id = $7 AND id = $8
+		RETURNING id`
+
+	args := []interface{}{episode.SeasonNumber, episode.Number, episode.Title, episode.AirDate, episode.Runtime, episode.Synopsis, episode.DoramaID, episode.ID}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := em.DB.QueryRowContext(ctx, query, args...).Scan(&episode.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return episodeError(err)
+		}
+	}
+	return nil
+}
+
+func (em *EpisodeModel) Delete(doramaID, id int) error {
+	query := `
+		DELETE FROM episodes
+		WHERE dorama_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := em.DB.ExecContext(ctx, query, doramaID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// episodeError translates constraint violations on the episodes table into the errors
+// that handlers know how to report: a clash on the (dorama, season, episode) key, or a
+// season number that the dorama doesn't have.
+func episodeError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505":
+			return ErrDuplicateEpisode
+		case "23503":
+			return ErrUnknownSeason
+		}
+	}
+	return err
+}
@@ -7,11 +7,25 @@ import (
 	"time"
 	"fmt"
 	"errors"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrUnknownGenre = errors.New("unknown genre")
 )
 
 type Genre struct {
 	GenreID      int    `json:"genre_id"`
 	GenreName    string `json:"genre_name"`
+	Version      int    `json:"version"`
+	Language     string `json:"language,omitempty"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.GenreName != "", "genre_name", "must be provided")
+	v.Check(len(genre.GenreName) <= 255, "genre_name", "must not be more than 255 bytes long")
 }
 
 type GenreModel struct {
@@ -24,9 +38,10 @@ func (m GenreModel) GetAll(GenreName string, GenreID int, filters Filters) ([]*G
     // Construct the SQL query
     query := fmt.Sprintf(
         `
-        SELECT count(*) OVER(), genre_id, genre_name
+        SELECT count(*) OVER(), genre_id, genre_name, version
         FROM genres
-        WHERE (to_tsvector('simple', genre_name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+        WHERE deleted_at IS NULL
+        AND (to_tsvector('simple', genre_name) @@ plainto_tsquery('simple', $1) OR $1 = '')
         AND (genre_id = $2 OR $2 = 1)
         ORDER BY %s %s, genre_id
         LIMIT $3 OFFSET $4`,
@@ -37,7 +52,7 @@ func (m GenreModel) GetAll(GenreName string, GenreID int, filters Filters) ([]*G
     defer cancel()
 
     // Organize our placeholder parameter values in a slice.
-    args := []interface{}{GenreName, GenreID, filters.PageSize, filters.Page}
+    args := []interface{}{GenreName, GenreID, filters.limit(), filters.offset()}
 
     // Use QueryContext to execute the query.
     rows, err := m.DB.QueryContext(ctx, query, args...)
@@ -56,7 +71,7 @@ func (m GenreModel) GetAll(GenreName string, GenreID int, filters Filters) ([]*G
     var genres []*Genre
     for rows.Next() {
         var genre Genre
-        err := rows.Scan(&totalRecords, &genre.GenreID, &genre.GenreName)
+        err := rows.Scan(&totalRecords, &genre.GenreID, &genre.GenreName, &genre.Version)
         if err != nil {
             return nil, Metadata{}, err
         }
@@ -75,22 +90,28 @@ func (m GenreModel) GetAll(GenreName string, GenreID int, filters Filters) ([]*G
 }
 
 
-func (gm *GenreModel) Get(id int) (*Genre,error) {
-
-	query := `
-		SELECT genre_id, genre_name
-		FROM genres
-		where genre_id = $1
-	`
+func (gm *GenreModel) Get(id int) (*Genre, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	
-	genre:= &Genre{}
-	err := gm.DB.QueryRowContext(ctx, query, id).Scan(&genre.GenreID, &genre.GenreName)
+
+	return gm.get(ctx, gm.DB, id)
+}
+
+// get looks up a live genre through q, which is either the connection pool or a
+// transaction that is about to modify the same record.
+func (gm *GenreModel) get(ctx context.Context, q queryer, id int) (*Genre, error) {
+	query := `
+        SELECT genre_id, genre_name, version
+        FROM genres
+        WHERE genre_id = $1 AND deleted_at IS NULL
+    `
+
+	genre := &Genre{}
+	err := q.QueryRowContext(ctx, query, id).Scan(&genre.GenreID, &genre.GenreName, &genre.Version)
 	
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("genre not found")
+			return nil, ErrRecordNotFound
 		} else {
 			return nil, err
 		} 
@@ -98,42 +119,236 @@ func (gm *GenreModel) Get(id int) (*Genre,error) {
 
 	return genre, nil
 }
- 
-func (gm *GenreModel) Insert(genre *Genre) error {
+
+// Insert adds a new genre and records the change as made by userID.
+func (gm *GenreModel) Insert(genre *Genre, userID int64) error {
+	query := `
+		INSERT INTO genres (genre_name) 
+		VALUES ($1) 
+		RETURNING genre_id, version
+		`
+	args := []interface{}{genre.GenreName}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := gm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.GenreID, &genre.Version)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "genre", genre.GenreID, genre.Version, RevisionInsert, userID, nil, genre)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Update saves the genre only if the stored version still matches genre.Version, and
+// returns ErrEditConflict if someone else has changed the record in the meantime.
+func (gm *GenreModel) Update(genre *Genre, userID int64) error {
+    query := `
+        UPDATE genres
+        SET genre_name = $1, version = version + 1
+        WHERE genre_id = $2 AND version = $3 AND deleted_at IS NULL
+        RETURNING version
+    `
+    args := []interface{}{genre.GenreName, genre.GenreID, genre.Version}
+    ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+    defer cancel()
+
+	tx, err := gm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := gm.get(ctx, tx, genre.GenreID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, "genre", genre.GenreID, genre.Version, RevisionUpdate, userID, before, genre)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete moves the genre to the trash, provided it is still at the given version. It
+// returns ErrEditConflict otherwise.
+func (gm *GenreModel) Delete(id int, version int, userID int64) error {
 	query := `
-  INSERT INTO genres (genre_name) 
-  VALUES ($1) 
-  RETURNING genre_id
- `
-	args := []interface{}{genre.GenreID, genre.GenreName}
+        UPDATE genres
+        SET deleted_at = NOW(), version = version + 1
+        WHERE genre_id = $1 AND version = $2 AND deleted_at IS NULL
+        RETURNING version
+	`
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return gm.DB.QueryRowContext(ctx, query, args...).Scan(&genre.GenreID)
+	tx, err := gm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := gm.get(ctx, tx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	var newVersion int
+	err = tx.QueryRowContext(ctx, query, id, version).Scan(&newVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = recordRevision(ctx, tx, "genre", id, newVersion, RevisionDelete, userID, before, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (gm *GenreModel) Update(genre *Genre) error {
+// Restore takes a genre back out of the trash.
+func (gm *GenreModel) Restore(id int, userID int64) error {
 	query := `
-  UPDATE genres
-  SET genre_name = $1
-  WHERE genre_id = $2
-  RETURNING genre_id
- `
-	args := []interface{}{genre.GenreName, genre.GenreID}
+		UPDATE genres
+		SET deleted_at = NULL, version = version + 1
+		WHERE genre_id = $1 AND deleted_at IS NOT NULL
+		RETURNING genre_id`
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return gm.DB.QueryRowContext(ctx, query, args...).Scan(&genre.GenreID)
+	tx, err := gm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	after, err := gm.get(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = recordRevision(ctx, tx, "genre", id, after.Version, RevisionRestore, userID, nil, after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (gm *GenreModel) Delete(id int) error {
+// GetAllForDorama returns the genres a dorama belongs to, ordered by name.
+func (gm *GenreModel) GetAllForDorama(doramaID int) ([]*Genre, error) {
 	query := `
-  DELETE FROM genres
-  WHERE genre_id = $1
- `
+		SELECT genres.genre_id, genres.genre_name
+		FROM genres
+		INNER JOIN doramas_genres ON doramas_genres.genre_id = genres.genre_id
+		WHERE doramas_genres.dorama_id = $1 AND genres.deleted_at IS NULL
+		ORDER BY genres.genre_name`
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	_, err := gm.DB.ExecContext(ctx, query, id)
-	return err
+	rows, err := gm.DB.QueryContext(ctx, query, doramaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(&genre.GenreID, &genre.GenreName)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return genres, nil
+}
+
+// setDoramaGenres replaces the genre associations of a dorama inside an existing
+// transaction. Links to trashed genres are left alone so that they come back if the
+// genre is restored. Any ID that isn't a live genre is reported as ErrUnknownGenre.
+func setDoramaGenres(ctx context.Context, tx *sql.Tx, doramaID int, genreIDs []int64) error {
+	query := `
+		DELETE FROM doramas_genres
+		WHERE dorama_id = $1
+		AND genre_id NOT IN (SELECT genre_id FROM genres WHERE deleted_at IS NOT NULL)`
+
+	_, err := tx.ExecContext(ctx, query, doramaID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO doramas_genres (dorama_id, genre_id)
+		SELECT $1, genre_id FROM genres
+		WHERE genre_id = ANY($2) AND deleted_at IS NULL
+		ON CONFLICT DO NOTHING`
+
+	result, err := tx.ExecContext(ctx, query, doramaID, pq.Array(genreIDs))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected != int64(len(genreIDs)) {
+		return ErrUnknownGenre
+	}
+	return nil
 }
@@ -0,0 +1,490 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrDuplicateListItem = errors.New("duplicate list item")
+	ErrInvalidListOrder  = errors.New("invalid list order")
+)
+
+// Define constants for who can see a list. Unlisted lists can be opened by anyone who
+// has the link but don't show up in discovery.
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
+
+var ListVisibilities = []string{VisibilityPrivate, VisibilityUnlisted, VisibilityPublic}
+
+// List is an ordered selection of doramas put together by a user, such as "Best
+// historical dramas". Items is only filled in when a single list is shown.
+type List struct {
+	ID            int64       `json:"id"`
+	UserID        int64       `json:"user_id"`
+	OwnerName     string      `json:"owner_name"`
+	Title         string      `json:"title"`
+	Description   string      `json:"description"`
+	Visibility    string      `json:"visibility"`
+	ItemCount     int         `json:"item_count"`
+	FollowerCount int         `json:"follower_count"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	Version       int         `json:"version"`
+	Items         []*ListItem `json:"items,omitempty"`
+}
+
+// ListItem is a dorama on a list. Positions start at 1 and have no gaps.
+type ListItem struct {
+	DoramaID           int    `json:"dorama_id"`
+	Title              string `json:"title"`
+	ReleaseYear        int    `json:"release_year"`
+	PosterThumbnailURL string `json:"poster_thumbnail_url,omitempty"`
+	Position           int    `json:"position"`
+	Note               string `json:"note"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	v.Check(list.Title != "", "title", "must be provided")
+	v.Check(len(list.Title) <= 255, "title", "must not be more than 255 bytes long")
+	v.Check(len(list.Description) <= 5000, "description", "must not be more than 5000 bytes long")
+	v.Check(validator.In(list.Visibility, ListVisibilities...), "visibility", "must be one of private, unlisted or public")
+}
+
+func ValidateListItem(v *validator.Validator, item *ListItem) {
+	v.Check(len(item.Note) <= 1000, "note", "must not be more than 1000 bytes long")
+}
+
+// VisibleTo reports whether a user may see the list.
+func (l *List) VisibleTo(user *User) bool {
+	return l.Visibility != VisibilityPrivate || l.UserID == user.ID
+}
+
+type ListModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// listColumns returns the columns selected by every list query, with the names the sort
+// safelists refer to. The item count leaves out doramas in the trash and those rated
+// above the maturity level passed in placeholder maturityArg, like GetItems does.
+func listColumns(maturityArg int) string {
+	return fmt.Sprintf(`
+	lists.id, lists.user_id, users.name, lists.title AS title, lists.description, lists.visibility,
+	(SELECT count(*) FROM list_items INNER JOIN doramas USING (dorama_id)
+		WHERE list_items.list_id = lists.id AND doramas.deleted_at IS NULL AND doramas.min_age <= $%d),
+	(SELECT count(*) FROM list_followers WHERE list_followers.list_id = lists.id) AS follower_count,
+	lists.created_at AS created_at, lists.updated_at AS updated_at, lists.version`,
+		maturityArg)
+}
+
+// GetAllPublic returns a page of the public lists, optionally only those whose title
+// matches. This is what other users can discover.
+func (m ListModel) GetAllPublic(title string, maturityLevel int, filters Filters) ([]*List, Metadata, error) {
+	where := `lists.visibility = 'public' AND (to_tsvector('simple', lists.title) @@ plainto_tsquery('simple', $1) OR $1 = '')`
+	return m.getAll(where, []interface{}{title}, maturityLevel, filters)
+}
+
+// GetAllForUser returns a page of the lists a user owns, whatever their visibility.
+func (m ListModel) GetAllForUser(userID int64, maturityLevel int, filters Filters) ([]*List, Metadata, error) {
+	where := `lists.user_id = $1`
+	return m.getAll(where, []interface{}{userID}, maturityLevel, filters)
+}
+
+// GetAllFollowed returns a page of the lists a user follows, leaving out any that their
+// owners have since made private.
+func (m ListModel) GetAllFollowed(userID int64, maturityLevel int, filters Filters) ([]*List, Metadata, error) {
+	where := `EXISTS (SELECT 1 FROM list_followers WHERE list_followers.list_id = lists.id AND list_followers.user_id = $1)
+		AND (lists.visibility <> 'private' OR lists.user_id = $1)`
+	return m.getAll(where, []interface{}{userID}, maturityLevel, filters)
+}
+
+// getAll runs a list query restricted by where, whose placeholders are filled in from
+// args, and pages through the results. Item counts are taken at maturityLevel.
+func (m ListModel) getAll(where string, args []interface{}, maturityLevel int, filters Filters) ([]*List, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM lists
+		INNER JOIN users ON users.id = lists.user_id
+		WHERE %s
+		ORDER BY %s %s, lists.id
+		LIMIT $%d OFFSET $%d`,
+		listColumns(len(args)+1), where, filters.sortColumn(), filters.sortDirection(), len(args)+2, len(args)+3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args = append(args, maturityLevel, filters.limit(), filters.offset())
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	lists := []*List{}
+	for rows.Next() {
+		var list List
+		err := rows.Scan(&totalRecords, &list.ID, &list.UserID, &list.OwnerName, &list.Title, &list.Description, &list.Visibility,
+			&list.ItemCount, &list.FollowerCount, &list.CreatedAt, &list.UpdatedAt, &list.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		lists = append(lists, &list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return lists, metadata, nil
+}
+
+// Get returns a list without its items, counting only the items visible at
+// maturityLevel. Callers have to check that the user asking is allowed to see it.
+func (m ListModel) Get(id int64, maturityLevel int) (*List, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM lists
+		INNER JOIN users ON users.id = lists.user_id
+		WHERE lists.id = $1`,
+		listColumns(2))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	list := &List{}
+	err := m.DB.QueryRowContext(ctx, query, id, maturityLevel).Scan(&list.ID, &list.UserID, &list.OwnerName, &list.Title, &list.Description, &list.Visibility,
+		&list.ItemCount, &list.FollowerCount, &list.CreatedAt, &list.UpdatedAt, &list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// GetItems returns the doramas on a list in order, leaving out doramas in the trash and
+// those rated above maturityLevel.
+func (m ListModel) GetItems(listID int64, maturityLevel int) ([]*ListItem, error) {
+	query := `
+		SELECT list_items.dorama_id, doramas.title, COALESCE(doramas.release_year, 0), doramas.poster_thumbnail_url,
+			list_items.position, list_items.note
+		FROM list_items
+		INNER JOIN doramas ON doramas.dorama_id = list_items.dorama_id
+		WHERE list_items.list_id = $1 AND doramas.deleted_at IS NULL AND doramas.min_age <= $2
+		ORDER BY list_items.position`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID, maturityLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*ListItem{}
+	for rows.Next() {
+		var item ListItem
+		err := rows.Scan(&item.DoramaID, &item.Title, &item.ReleaseYear, &item.PosterThumbnailURL, &item.Position, &item.Note)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (m ListModel) Insert(list *List) error {
+	query := `
+		INSERT INTO lists (user_id, title, description, visibility)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version`
+
+	args := []interface{}{list.UserID, list.Title, list.Description, list.Visibility}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.UpdatedAt, &list.Version)
+}
+
+// Update saves the list only if the stored version still matches list.Version, and
+// returns ErrEditConflict otherwise.
+func (m ListModel) Update(list *List) error {
+	query := `
+		UPDATE lists
+		SET title = $1, description = $2, visibility = $3, updated_at = NOW(), version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version`
+
+	args := []interface{}{list.Title, list.Description, list.Visibility, list.ID, list.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.UpdatedAt, &list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ListModel) Delete(id int64) error {
+	query := `
+		DELETE FROM lists
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// AddItem appends a dorama to the end of a list. It returns ErrDuplicateListItem if the
+// dorama is already on it.
+func (m ListModel) AddItem(listID int64, item *ListItem) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the list keeps two concurrent additions from taking the same position.
+	err = touchList(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO list_items (list_id, dorama_id, position, note)
+		SELECT $1, $2, COALESCE(max(position), 0) + 1, $3
+		FROM list_items
+		WHERE list_id = $1
+		RETURNING position`
+
+	err = tx.QueryRowContext(ctx, query, listID, item.DoramaID, item.Note).Scan(&item.Position)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateListItem
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RemoveItem takes a dorama off a list and closes the gap it leaves.
+func (m ListModel) RemoveItem(listID int64, doramaID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = touchList(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM list_items
+		WHERE list_id = $1 AND dorama_id = $2
+		RETURNING position`
+
+	var position int
+	err = tx.QueryRowContext(ctx, query, listID, doramaID).Scan(&position)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	query = `
+		UPDATE list_items
+		SET position = position - 1
+		WHERE list_id = $1 AND position > $2`
+
+	_, err = tx.ExecContext(ctx, query, listID, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reorder puts the items of a list that are visible at maturityLevel in the order of
+// doramaIDs, which must name each of them exactly once. It returns ErrInvalidListOrder
+// otherwise. Items in the trash or rated above maturityLevel keep their positions, and
+// the visible items are shuffled around them.
+func (m ListModel) Reorder(listID int64, doramaIDs []int64, maturityLevel int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = touchList(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT count(*), count(*) FILTER (WHERE list_items.dorama_id = ANY($2))
+		FROM list_items
+		INNER JOIN doramas ON doramas.dorama_id = list_items.dorama_id
+		WHERE list_items.list_id = $1 AND doramas.deleted_at IS NULL AND doramas.min_age <= $3`
+
+	var total, matched int
+	err = tx.QueryRowContext(ctx, query, listID, pq.Array(doramaIDs), maturityLevel).Scan(&total, &matched)
+	if err != nil {
+		return err
+	}
+	if total != len(doramaIDs) || matched != total {
+		return ErrInvalidListOrder
+	}
+
+	// The visible items take over the positions they held between them, in the new
+	// order, so the hidden ones stay where they were.
+	query = `
+		WITH slots AS (
+			SELECT list_items.position, row_number() OVER (ORDER BY list_items.position) AS n
+			FROM list_items
+			INNER JOIN doramas ON doramas.dorama_id = list_items.dorama_id
+			WHERE list_items.list_id = $1 AND doramas.deleted_at IS NULL AND doramas.min_age <= $3
+		)
+		UPDATE list_items
+		SET position = slots.position
+		FROM slots
+		WHERE list_items.list_id = $1 AND slots.n = array_position($2, list_items.dorama_id::bigint)`
+
+	_, err = tx.ExecContext(ctx, query, listID, pq.Array(doramaIDs), maturityLevel)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// touchList locks a list for the rest of the transaction and marks it as updated. It
+// returns ErrRecordNotFound if the list doesn't exist.
+func touchList(ctx context.Context, tx *sql.Tx, listID int64) error {
+	query := `
+		UPDATE lists
+		SET updated_at = NOW()
+		WHERE id = $1`
+
+	result, err := tx.ExecContext(ctx, query, listID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Follow subscribes a user to a list. Following a list twice is not an error.
+func (m ListModel) Follow(listID, userID int64) error {
+	query := `
+		INSERT INTO list_followers (list_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, listID, userID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Unfollow removes a user's subscription to a list, and returns ErrRecordNotFound if
+// they weren't following it.
+func (m ListModel) Unfollow(listID, userID int64) error {
+	query := `
+		DELETE FROM list_followers
+		WHERE list_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, listID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package model
+
+import "testing"
+
+func TestReorderKeepsHiddenItemsInPlace(t *testing.T) {
+	models := newTestModels(t)
+
+	const restricted = 15
+	user := insertTestUser(t, models, restricted)
+	first := insertTestDorama(t, models, &Dorama{})
+	adult := insertTestDorama(t, models, &Dorama{RatingSystem: "KMRB", ContentRating: "19"})
+	trashed := insertTestDorama(t, models, &Dorama{})
+	last := insertTestDorama(t, models, &Dorama{})
+
+	list := &List{UserID: user.ID, Title: "Test list", Visibility: VisibilityPrivate}
+	err := models.Lists.Insert(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dorama := range []*Dorama{first, adult, trashed, last} {
+		err := models.Lists.AddItem(list.ID, &ListItem{DoramaID: dorama.DoramaId})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = models.Doramas.Delete(trashed.DoramaId, trashed.Version, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := models.Lists.Get(list.ID, restricted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ItemCount != 2 {
+		t.Errorf("got item count %d; want 2", got.ItemCount)
+	}
+
+	err = models.Lists.Reorder(list.ID, []int64{int64(last.DoramaId), int64(first.DoramaId)}, restricted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := models.Lists.GetItems(list.ID, MaturityAdult)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The visible items swap the first and last places; the adult one stays second.
+	want := []int{last.DoramaId, adult.DoramaId, first.DoramaId}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items; want %d", len(items), len(want))
+	}
+	for i, item := range items {
+		if item.DoramaID != want[i] {
+			t.Errorf("item %d: got dorama %d; want %d", i, item.DoramaID, want[i])
+		}
+	}
+
+	err = models.Lists.Reorder(list.ID, []int64{int64(first.DoramaId)}, restricted)
+	if err != ErrInvalidListOrder {
+		t.Errorf("got %v; want ErrInvalidListOrder", err)
+	}
+}
@@ -0,0 +1,158 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRestrictedUserCannotReachAdultTitles links an adult title to a family one in every
+// way the catalog allows, and checks that none of them leads a user with a lower
+// maturity level to it.
+func TestRestrictedUserCannotReachAdultTitles(t *testing.T) {
+	models := newTestModels(t)
+
+	const restricted = 15
+	today := time.Now().Format("2006-01-02")
+	nextWeek := time.Now().AddDate(0, 0, 6).Format("2006-01-02")
+
+	family := insertTestDorama(t, models, &Dorama{Status: StatusAiring, StartDate: today})
+	adult := insertTestDorama(t, models, &Dorama{Status: StatusAiring, StartDate: today, RatingSystem: "KMRB", ContentRating: "19"})
+	actor := insertTestActor(t, models)
+	user := insertTestUser(t, models, restricted)
+
+	for _, dorama := range []*Dorama{family, adult} {
+		for weekday := 1; weekday <= 7; weekday++ {
+			err := models.Schedule.Insert(&BroadcastSlot{DoramaID: dorama.DoramaId, Weekday: weekday, AirTime: "21:00", Timezone: "Asia/Seoul"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := models.Doramas.SetCredit(&Credit{DoramaID: dorama.DoramaId, ActorID: actor.ActorId, Role: RoleActor}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = models.Watchlist.Set(user.ID, &WatchlistEntry{DoramaID: dorama.DoramaId, State: WatchStatePlanToWatch})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := models.Doramas.SetRelation(&Relation{DoramaID: adult.DoramaId, RelatedID: family.DoramaId, Relation: "sequel"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// found reports whether the adult title is among the given dorama IDs.
+	found := func(ids []int) bool {
+		for _, id := range ids {
+			if id == adult.DoramaId {
+				return true
+			}
+		}
+		return false
+	}
+
+	tests := []struct {
+		name string
+		ids  func(maturityLevel int) []int
+	}{
+		{"related", func(maturityLevel int) []int {
+			related, err := models.Doramas.GetRelated(family.DoramaId, maturityLevel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, d := range related {
+				ids = append(ids, d.DoramaID)
+			}
+			return ids
+		}},
+		{"franchise", func(maturityLevel int) []int {
+			franchise, err := models.Doramas.GetFranchise(family.DoramaId, maturityLevel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, d := range franchise.Doramas {
+				ids = append(ids, d.DoramaID)
+			}
+			for _, relation := range franchise.Relations {
+				ids = append(ids, relation.DoramaID, relation.RelatedID)
+			}
+			return ids
+		}},
+		{"schedule", func(maturityLevel int) []int {
+			filters := Filters{Page: 1, PageSize: 100, Sort: "air_date", SortSafelist: []string{"air_date"}}
+			schedule, _, err := models.Schedule.GetSchedule(today, nextWeek, maturityLevel, filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, entry := range schedule {
+				ids = append(ids, entry.DoramaID)
+			}
+			return ids
+		}},
+		{"filmography", func(maturityLevel int) []int {
+			filmography, err := models.Actors.GetFilmography(actor.ActorId, "", maturityLevel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, credit := range filmography {
+				ids = append(ids, credit.DoramaID)
+			}
+			return ids
+		}},
+		{"watchlist", func(maturityLevel int) []int {
+			filters := Filters{Page: 1, PageSize: 100, Sort: "title", SortSafelist: []string{"title"}}
+			entries, _, err := models.Watchlist.GetAll(user.ID, nil, maturityLevel, filters)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, entry := range entries {
+				ids = append(ids, entry.DoramaID)
+			}
+			return ids
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if found(tt.ids(restricted)) {
+				t.Errorf("adult title reachable at maturity level %d", restricted)
+			}
+			if !found(tt.ids(MaturityAdult)) {
+				t.Errorf("adult title not reachable at maturity level %d", MaturityAdult)
+			}
+		})
+	}
+
+	t.Run("watchlist entry", func(t *testing.T) {
+		_, err := models.Watchlist.Get(user.ID, adult.DoramaId, restricted)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("got %v; want ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("dorama", func(t *testing.T) {
+		_, err := models.Doramas.Get(adult.DoramaId, restricted)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("got %v; want ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		minAge, err := models.Doramas.GetMinAge(adult.DoramaId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if minAge <= restricted {
+			t.Errorf("got min age %d; want it above %d", minAge, restricted)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"os"
@@ -12,10 +13,33 @@ var (
 	ErrEditConflict = errors.New("edit conflict")
 )
 
+// queryer is satisfied by both *sql.DB and *sql.Tx, so that a query can run either on
+// its own or inside a transaction that changes more than one record.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 type Models struct {
 	Doramas DoramaModel
 	Actors ActorModel
 	Genres GenreModel
+	Seasons SeasonModel
+	Episodes EpisodeModel
+	Trash TrashModel
+	Revisions RevisionModel
+	Translations TranslationModel
+	Aliases AliasModel
+	Companies CompanyModel
+	Countries CountryModel
+	Schedule ScheduleModel
+	Reviews ReviewModel
+	Watchlist WatchlistModel
+	Lists ListModel
+	Comments CommentModel
+	Moderation ModerationModel
+	Outbox OutboxModel
 	Users UserModel
 	Tokens TokenModel
 	Permissions PermissionModel
@@ -40,6 +64,81 @@ func NewModels(db *sql.DB) Models {
 			InfoLog:  infoLog,
 			ErrorLog: errorLog,
 		},
+		Seasons: SeasonModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Episodes: EpisodeModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Trash: TrashModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Revisions: RevisionModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Translations: TranslationModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Aliases: AliasModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Companies: CompanyModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Countries: CountryModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Schedule: ScheduleModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Reviews: ReviewModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Watchlist: WatchlistModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Lists: ListModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Comments: CommentModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Moderation: ModerationModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
+		Outbox: OutboxModel{
+			DB:       db,
+			InfoLog:  infoLog,
+			ErrorLog: errorLog,
+		},
 		Permissions: PermissionModel{DB: db},
 		Tokens: TokenModel{DB: db}, 
 		Users: UserModel{DB: db},
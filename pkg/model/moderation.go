@@ -0,0 +1,313 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrDuplicateReport = errors.New("duplicate report")
+	ErrModerationNoOp  = errors.New("moderation action has no effect")
+)
+
+// Define constants for the kinds of user content that can be reported and moderated.
+const (
+	TargetReview  = "review"
+	TargetComment = "comment"
+)
+
+var ModerationTargets = []string{TargetReview, TargetComment}
+
+var ReportReasons = []string{"spam", "harassment", "spoiler", "off_topic", "other"}
+
+// Define constants for the decisions a moderator can make about reported content.
+// Every decision closes the open reports on the content.
+const (
+	ModerationHide    = "hide"
+	ModerationRestore = "restore"
+	ModerationDismiss = "dismiss"
+)
+
+var ModerationActions = []string{ModerationHide, ModerationRestore, ModerationDismiss}
+
+// moderationTables maps each target type to the table holding the content and the
+// column of the reports table that refers to it.
+var moderationTables = map[string]struct{ table, reportColumn string }{
+	TargetReview:  {"reviews", "review_id"},
+	TargetComment: {"review_comments", "comment_id"},
+}
+
+// Report is a user's complaint about a review or a comment.
+type Report struct {
+	ID         int64     `json:"id"`
+	TargetType string    `json:"target_type"`
+	TargetID   int64     `json:"target_id"`
+	UserID     int64     `json:"user_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func ValidateReport(v *validator.Validator, report *Report) {
+	v.Check(validator.In(report.Reason, ReportReasons...), "reason", "must be one of spam, harassment, spoiler, off_topic or other")
+	v.Check(len(report.Details) <= 2000, "details", "must not be more than 2000 bytes long")
+}
+
+// QueueItem is a piece of content with open reports, as a moderator sees it. Hidden
+// tells whether the content is currently hidden from other users.
+type QueueItem struct {
+	TargetType      string    `json:"target_type"`
+	TargetID        int64     `json:"target_id"`
+	DoramaID        int       `json:"dorama_id"`
+	ReviewID        int64     `json:"review_id"`
+	AuthorID        int64     `json:"author_id"`
+	AuthorName      string    `json:"author_name"`
+	Body            string    `json:"body"`
+	Hidden          bool      `json:"hidden"`
+	ReportCount     int       `json:"report_count"`
+	Reasons         []string  `json:"reasons"`
+	FirstReportedAt time.Time `json:"first_reported_at"`
+	LastReportedAt  time.Time `json:"last_reported_at"`
+}
+
+// ModerationAction is an entry in the audit trail of moderator decisions.
+// ReportsResolved counts the reports the decision closed, and is ignored on writes.
+type ModerationAction struct {
+	ID              int64     `json:"id"`
+	TargetType      string    `json:"target_type"`
+	TargetID        int64     `json:"target_id"`
+	ModeratorID     int64     `json:"moderator_id"`
+	ModeratorName   string    `json:"moderator_name"`
+	Action          string    `json:"action"`
+	Reason          string    `json:"reason"`
+	ReportsResolved int       `json:"reports_resolved"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func ValidateModerationAction(v *validator.Validator, action *ModerationAction) {
+	v.Check(validator.In(action.TargetType, ModerationTargets...), "target_type", "must be review or comment")
+	v.Check(validator.In(action.Action, ModerationActions...), "action", "must be one of hide, restore or dismiss")
+	v.Check(len(action.Reason) <= 2000, "reason", "must not be more than 2000 bytes long")
+}
+
+type ModerationModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// Report files a report, and returns ErrDuplicateReport if the user has already
+// reported the same content.
+func (m ModerationModel) Report(report *Report) error {
+	target, ok := moderationTables[report.TargetType]
+	if !ok {
+		return fmt.Errorf("unknown moderation target %q", report.TargetType)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO reports (%s, user_id, reason, details)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		target.reportColumn)
+
+	args := []interface{}{report.TargetID, report.UserID, report.Reason, report.Details}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&report.ID, &report.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateReport
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// GetQueue returns a page of the content with open reports, optionally only of one
+// target type.
+func (m ModerationModel) GetQueue(targetType string, filters Filters) ([]*QueueItem, Metadata, error) {
+	query := fmt.Sprintf(`
+		WITH open_reports AS (
+			SELECT review_id, comment_id, count(*) AS report_count, array_agg(DISTINCT reason) AS reasons,
+				min(created_at) AS first_reported_at, max(created_at) AS last_reported_at
+			FROM reports
+			WHERE resolved_at IS NULL
+			GROUP BY review_id, comment_id
+		)
+		SELECT count(*) OVER(), CASE WHEN open_reports.comment_id IS NULL THEN 'review' ELSE 'comment' END AS target_type,
+			COALESCE(open_reports.review_id, open_reports.comment_id) AS target_id, reviews.dorama_id, reviews.id,
+			users.id, users.name, COALESCE(review_comments.body, reviews.body),
+			COALESCE(review_comments.hidden_at, reviews.hidden_at) IS NOT NULL,
+			open_reports.report_count AS report_count, open_reports.reasons,
+			open_reports.first_reported_at AS first_reported_at, open_reports.last_reported_at AS last_reported_at
+		FROM open_reports
+		LEFT JOIN review_comments ON review_comments.id = open_reports.comment_id
+		INNER JOIN reviews ON reviews.id = COALESCE(open_reports.review_id, review_comments.review_id)
+		INNER JOIN users ON users.id = COALESCE(review_comments.user_id, reviews.user_id)
+		WHERE $1 = '' OR (open_reports.comment_id IS NULL) = ($1 = 'review')
+		ORDER BY %s %s, target_type, target_id
+		LIMIT $2 OFFSET $3`,
+		filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, targetType, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*QueueItem{}
+	for rows.Next() {
+		var item QueueItem
+		err := rows.Scan(&totalRecords, &item.TargetType, &item.TargetID, &item.DoramaID, &item.ReviewID,
+			&item.AuthorID, &item.AuthorName, &item.Body, &item.Hidden,
+			&item.ReportCount, pq.Array(&item.Reasons), &item.FirstReportedAt, &item.LastReportedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+// Moderate applies a moderator's decision to a review or comment, closes its open
+// reports and records the decision in the audit trail, all in one transaction. It
+// returns ErrRecordNotFound if the content doesn't exist, and ErrModerationNoOp when
+// hiding content that is already hidden or restoring content that isn't.
+func (m ModerationModel) Moderate(action *ModerationAction) error {
+	target, ok := moderationTables[action.TargetType]
+	if !ok {
+		return fmt.Errorf("unknown moderation target %q", action.TargetType)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hidden bool
+	query := fmt.Sprintf(`SELECT hidden_at IS NOT NULL FROM %s WHERE id = $1 FOR UPDATE`, target.table)
+	err = tx.QueryRowContext(ctx, query, action.TargetID).Scan(&hidden)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	switch action.Action {
+	case ModerationHide, ModerationRestore:
+		if hidden == (action.Action == ModerationHide) {
+			return ErrModerationNoOp
+		}
+		query = fmt.Sprintf(`UPDATE %s SET hidden_at = CASE WHEN $2 THEN NOW() END WHERE id = $1`, target.table)
+		_, err = tx.ExecContext(ctx, query, action.TargetID, action.Action == ModerationHide)
+		if err != nil {
+			return err
+		}
+	}
+
+	query = `
+		INSERT INTO moderation_actions (target_type, target_id, moderator_id, action, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	args := []interface{}{action.TargetType, action.TargetID, action.ModeratorID, action.Action, action.Reason}
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&action.ID, &action.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf(`
+		UPDATE reports
+		SET resolved_at = NOW(), action_id = $2
+		WHERE %s = $1 AND resolved_at IS NULL`,
+		target.reportColumn)
+
+	result, err := tx.ExecContext(ctx, query, action.TargetID, action.ID)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	action.ReportsResolved = int(resolved)
+
+	return tx.Commit()
+}
+
+// GetActions returns a page of the audit trail, optionally narrowed to one target type
+// or one piece of content when targetID is not zero.
+func (m ModerationModel) GetActions(targetType string, targetID int64, filters Filters) ([]*ModerationAction, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), moderation_actions.id, moderation_actions.target_type, moderation_actions.target_id,
+			moderation_actions.moderator_id, COALESCE(users.name, ''), moderation_actions.action, moderation_actions.reason,
+			(SELECT count(*) FROM reports WHERE reports.action_id = moderation_actions.id),
+			moderation_actions.created_at AS created_at
+		FROM moderation_actions
+		LEFT JOIN users ON users.id = moderation_actions.moderator_id
+		WHERE ($1 = '' OR moderation_actions.target_type = $1)
+		AND ($2::bigint = 0 OR moderation_actions.target_id = $2)
+		ORDER BY %s %s, id
+		LIMIT $3 OFFSET $4`,
+		filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, targetType, targetID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	actions := []*ModerationAction{}
+	for rows.Next() {
+		var action ModerationAction
+		err := rows.Scan(&totalRecords, &action.ID, &action.TargetType, &action.TargetID,
+			&action.ModeratorID, &action.ModeratorName, &action.Action, &action.Reason,
+			&action.ReportsResolved, &action.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		actions = append(actions, &action)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return actions, metadata, nil
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Define constants for the states of an email in the outbox. Dead emails have failed
+// too often and won't be retried.
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailDead    = "dead"
+)
+
+// OutboxEmail is an email waiting to be rendered from Template with Data and sent to
+// Recipient by the delivery worker.
+type OutboxEmail struct {
+	ID            int64                  `json:"id"`
+	Recipient     string                 `json:"recipient"`
+	Template      string                 `json:"template"`
+	Data          map[string]interface{} `json:"-"`
+	Status        string                 `json:"status"`
+	Attempts      int                    `json:"attempts"`
+	LastError     string                 `json:"last_error"`
+	NextAttemptAt time.Time              `json:"next_attempt_at"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
+
+type OutboxModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// enqueueEmail adds an email to the outbox as part of tx, so that it is only sent if
+// the change it is about is committed, and is never lost if it is.
+func enqueueEmail(ctx context.Context, tx *sql.Tx, email *OutboxEmail) error {
+	data, err := json.Marshal(email.Data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO email_outbox (recipient, template, data)
+		VALUES ($1, $2, $3)
+		RETURNING id, status, next_attempt_at, created_at`
+
+	return tx.QueryRowContext(ctx, query, email.Recipient, email.Template, data).Scan(&email.ID, &email.Status, &email.NextAttemptAt, &email.CreatedAt)
+}
+
+// Claim returns up to limit pending emails that are due, and pushes their next attempt
+// back by lease so that no other worker picks them up while they are being sent. An
+// email whose worker dies is retried once the lease runs out.
+func (m OutboxModel) Claim(limit int, lease time.Duration) ([]*OutboxEmail, error) {
+	query := `
+		UPDATE email_outbox
+		SET next_attempt_at = NOW() + $2 * interval '1 second'
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, template, data, status, attempts, last_error, next_attempt_at, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []*OutboxEmail{}
+	for rows.Next() {
+		var email OutboxEmail
+		var data []byte
+		err := rows.Scan(&email.ID, &email.Recipient, &email.Template, &data, &email.Status, &email.Attempts,
+			&email.LastError, &email.NextAttemptAt, &email.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		// Numbers are decoded as json.Number, so that an ID renders in the email as it was
+		// stored rather than as a float such as 1.234567e+06.
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&email.Data)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, &email)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return emails, nil
+}
+
+// MarkSent records a successful delivery. The data is cleared, since it usually holds
+// a plaintext token that has no business staying in the database.
+func (m OutboxModel) MarkSent(email *OutboxEmail) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'sent', attempts = attempts + 1, last_error = '', data = '{}', sent_at = NOW()
+		WHERE id = $1
+		RETURNING status, attempts`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, email.ID).Scan(&email.Status, &email.Attempts)
+}
+
+// MarkFailed records a failed delivery. The email is retried at retryAt, or given up on
+// as dead if retryAt is the zero time, in which case its data is cleared like MarkSent
+// does.
+func (m OutboxModel) MarkFailed(email *OutboxEmail, deliveryErr error, retryAt time.Time) error {
+	query := `
+		UPDATE email_outbox
+		SET status = CASE WHEN $3 THEN 'dead' ELSE 'pending' END, attempts = attempts + 1, last_error = $2,
+			next_attempt_at = CASE WHEN $3 THEN next_attempt_at ELSE $4 END,
+			data = CASE WHEN $3 THEN '{}' ELSE data END
+		WHERE id = $1
+		RETURNING status, attempts, last_error, next_attempt_at`
+
+	args := []interface{}{email.ID, deliveryErr.Error(), retryAt.IsZero(), retryAt}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&email.Status, &email.Attempts, &email.LastError, &email.NextAttemptAt)
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// claimTestEmail claims the pending emails that are due and returns the one with the
+// given ID, failing the test if it isn't among them.
+func claimTestEmail(t *testing.T, models Models, id int64) *OutboxEmail {
+	t.Helper()
+
+	emails, err := models.Outbox.Claim(100, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range emails {
+		if email.ID == id {
+			return email
+		}
+	}
+	t.Fatalf("email %d was not claimed", id)
+	return nil
+}
+
+// storedEmailData returns the raw data column of an email.
+func storedEmailData(t *testing.T, models Models, id int64) string {
+	t.Helper()
+
+	var data string
+	err := models.Outbox.DB.QueryRow(`SELECT data FROM email_outbox WHERE id = $1`, id).Scan(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestOutboxDelivery(t *testing.T) {
+	models := newTestModels(t)
+	user := insertTestUser(t, models, MaturityAdult)
+
+	email := &OutboxEmail{Recipient: user.Email, Template: "password_reset.tmpl", Data: map[string]interface{}{"name": user.Name}}
+	token, err := models.Tokens.NewWithEmail(user.ID, time.Hour, ScopePasswordReset, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		models.Outbox.DB.Exec(`DELETE FROM email_outbox WHERE id = $1`, email.ID)
+	})
+
+	claimed := claimTestEmail(t, models, email.ID)
+	if claimed.Data["token"] != token.Plaintext {
+		t.Errorf("got token %v in the email; want %q", claimed.Data["token"], token.Plaintext)
+	}
+
+	// A claimed email is leased, so claiming again mustn't hand it out twice.
+	emails, err := models.Outbox.Claim(100, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range emails {
+		if e.ID == email.ID {
+			t.Error("email claimed twice within its lease")
+		}
+	}
+
+	retryAt := time.Now().Add(-time.Second)
+	err = models.Outbox.MarkFailed(claimed, errors.New("connection refused"), retryAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed.Status != EmailPending || claimed.Attempts != 1 || claimed.LastError != "connection refused" {
+		t.Errorf("after a retryable failure: got %q, %d attempts, %q", claimed.Status, claimed.Attempts, claimed.LastError)
+	}
+
+	claimed = claimTestEmail(t, models, email.ID)
+	err = models.Outbox.MarkSent(claimed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed.Status != EmailSent || claimed.Attempts != 2 {
+		t.Errorf("after sending: got %q, %d attempts", claimed.Status, claimed.Attempts)
+	}
+	if data := storedEmailData(t, models, email.ID); data != "{}" {
+		t.Errorf("got data %s after sending; want it cleared", data)
+	}
+}
+
+func TestOutboxGivesUp(t *testing.T) {
+	models := newTestModels(t)
+	user := insertTestUser(t, models, MaturityAdult)
+
+	email := &OutboxEmail{Recipient: user.Email, Template: "password_reset.tmpl"}
+	_, err := models.Tokens.NewWithEmail(user.ID, time.Hour, ScopePasswordReset, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		models.Outbox.DB.Exec(`DELETE FROM email_outbox WHERE id = $1`, email.ID)
+	})
+
+	claimed := claimTestEmail(t, models, email.ID)
+	err = models.Outbox.MarkFailed(claimed, errors.New("mailbox unavailable"), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed.Status != EmailDead {
+		t.Errorf("got status %q; want %q", claimed.Status, EmailDead)
+	}
+	if data := storedEmailData(t, models, email.ID); data != "{}" {
+		t.Errorf("got data %s for a dead email; want it cleared", data)
+	}
+}
+
+func TestInsertWithActivation(t *testing.T) {
+	models := newTestModels(t)
+
+	user := &User{Name: "Test user", Email: fmt.Sprintf("test-%d@example.com", time.Now().UnixNano()), MaturityLevel: MaturityAdult}
+	user.Password.hash = []byte("not a real hash")
+
+	email := &OutboxEmail{Recipient: user.Email, Template: "user_welcome.tmpl"}
+	token, err := models.Users.InsertWithActivation(user, []string{"movies:read"}, time.Hour, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		models.Users.DB.Exec(`DELETE FROM users WHERE id = $1`, user.ID)
+		models.Outbox.DB.Exec(`DELETE FROM email_outbox WHERE id = $1`, email.ID)
+	})
+
+	got, err := models.Users.GetForToken(ScopeActivation, token.Plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("token belongs to user %d; want %d", got.ID, user.ID)
+	}
+
+	permissions, err := models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !permissions.Include("movies:read") {
+		t.Errorf("got permissions %v; want movies:read", permissions)
+	}
+
+	claimed := claimTestEmail(t, models, email.ID)
+	if claimed.Data["token"] != token.Plaintext || claimed.Data["userID"] != json.Number(strconv.FormatInt(user.ID, 10)) {
+		t.Errorf("got email data %v; want the token and the user ID", claimed.Data)
+	}
+
+	// A second registration with the same address must leave nothing behind.
+	duplicate := &User{Name: "Test user", Email: user.Email, MaturityLevel: MaturityAdult}
+	duplicate.Password.hash = []byte("not a real hash")
+	_, err = models.Users.InsertWithActivation(duplicate, []string{"movies:read"}, time.Hour, &OutboxEmail{Recipient: user.Email, Template: "user_welcome.tmpl"})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Errorf("duplicate: got %v; want ErrDuplicateEmail", err)
+	}
+
+	var queued int
+	err = models.Outbox.DB.QueryRow(`SELECT count(*) FROM email_outbox WHERE recipient = $1`, user.Email).Scan(&queued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queued != 1 {
+		t.Errorf("got %d emails queued; want 1", queued)
+	}
+}
@@ -68,11 +68,17 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 // variadic parameter for the codes so that we can assign multiple permissions in a
 // single call.
 func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return addPermissionsForUser(ctx, m.DB, userID, codes...)
+}
+
+// addPermissionsForUser runs the insert for AddForUser with q, which is either the
+// connection pool or a transaction.
+func addPermissionsForUser(ctx context.Context, q queryer, userID int64, codes ...string) error {
 	query := `
 		INSERT INTO users_permissions
 		SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	_, err := q.ExecContext(ctx, query, userID, pq.Array(codes))
 	return err
 }
\ No newline at end of file
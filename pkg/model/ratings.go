@@ -0,0 +1,55 @@
+package model
+
+import (
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// MaturityAdult is the highest maturity level, at which every title is shown. A user's
+// maturity level is the oldest minimum age they want to see titles for, from 0 (only
+// titles suitable for all ages) up to MaturityAdult.
+const MaturityAdult = 18
+
+// RatingSystems maps each supported rating system to its ratings and the minimum age
+// each of them stands for. Adult-only ratings are all mapped to MaturityAdult, even
+// where the system itself counts ages differently.
+var RatingSystems = map[string]map[string]int{
+	// Korea Media Rating Board, used for films and streaming releases.
+	"KMRB": {"ALL": 0, "12": 12, "15": 15, "19": MaturityAdult},
+	// Korea Communications Standards Commission, used for broadcast television.
+	"KCSC": {"ALL": 0, "7": 7, "12": 12, "15": 15, "19": MaturityAdult},
+	// Motion Picture Association, for titles rated in the US.
+	"MPA": {"G": 0, "PG": 10, "PG-13": 13, "R": 17, "NC-17": MaturityAdult},
+}
+
+// ContentWarnings lists the warnings a dorama can be tagged with, independently of its
+// rating.
+var ContentWarnings = []string{"violence", "gore", "sexual_content", "nudity", "language", "drug_use", "self_harm", "suicide", "abuse", "horror", "flashing_lights"}
+
+// MinAge returns the minimum age a rating stands for, and false if the rating system or
+// the rating is unknown. An empty rating means the dorama is unrated, which counts as
+// suitable for all ages.
+func MinAge(system, rating string) (int, bool) {
+	if system == "" && rating == "" {
+		return 0, true
+	}
+	age, ok := RatingSystems[system][rating]
+	return age, ok
+}
+
+func ValidateContentRating(v *validator.Validator, dorama *Dorama) {
+	v.Check(dorama.RatingSystem == "" || RatingSystems[dorama.RatingSystem] != nil, "rating_system", "must be one of KMRB, KCSC or MPA")
+	v.Check(dorama.RatingSystem != "" || dorama.ContentRating == "", "rating_system", "must be provided with a content rating")
+	v.Check(dorama.ContentRating != "" || dorama.RatingSystem == "", "content_rating", "must be provided with a rating system")
+	if _, ok := MinAge(dorama.RatingSystem, dorama.ContentRating); !ok && RatingSystems[dorama.RatingSystem] != nil {
+		v.AddError("content_rating", "is not a rating of the "+dorama.RatingSystem+" system")
+	}
+
+	v.Check(validator.Unique(dorama.ContentWarnings), "content_warnings", "must not contain duplicate values")
+	for _, warning := range dorama.ContentWarnings {
+		v.Check(validator.In(warning, ContentWarnings...), "content_warnings", "must only contain known content warnings")
+	}
+}
+
+func ValidateMaturityLevel(v *validator.Validator, level int) {
+	v.Check(level >= 0 && level <= MaturityAdult, "maturity_level", "must be between 0 and 18")
+}
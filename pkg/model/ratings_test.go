@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+func TestMinAge(t *testing.T) {
+	tests := []struct {
+		system, rating string
+		want           int
+		wantOK         bool
+	}{
+		{"", "", 0, true},
+		{"KMRB", "ALL", 0, true},
+		{"KMRB", "15", 15, true},
+		{"KMRB", "19", MaturityAdult, true},
+		{"KCSC", "7", 7, true},
+		{"MPA", "PG-13", 13, true},
+		{"MPA", "NC-17", MaturityAdult, true},
+		{"MPA", "19", 0, false},
+		{"BBFC", "15", 0, false},
+		{"KMRB", "", 0, false},
+		{"", "15", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := MinAge(tt.system, tt.rating)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("MinAge(%q, %q) = %d, %t; want %d, %t", tt.system, tt.rating, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestValidateContentRating(t *testing.T) {
+	tests := []struct {
+		name   string
+		dorama *Dorama
+		errors []string
+	}{
+		{"unrated", &Dorama{}, nil},
+		{"rated", &Dorama{RatingSystem: "KCSC", ContentRating: "15", ContentWarnings: []string{"violence"}}, nil},
+		{"unknown system", &Dorama{RatingSystem: "BBFC", ContentRating: "15"}, []string{"rating_system"}},
+		{"rating of another system", &Dorama{RatingSystem: "MPA", ContentRating: "15"}, []string{"content_rating"}},
+		{"rating without system", &Dorama{ContentRating: "15"}, []string{"rating_system"}},
+		{"system without rating", &Dorama{RatingSystem: "KMRB"}, []string{"content_rating"}},
+		{"unknown warning", &Dorama{ContentWarnings: []string{"spoilers"}}, []string{"content_warnings"}},
+		{"repeated warning", &Dorama{ContentWarnings: []string{"gore", "gore"}}, []string{"content_warnings"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateContentRating(v, tt.dorama)
+
+			if len(v.Errors) != len(tt.errors) {
+				t.Errorf("got errors %v; want errors for %v", v.Errors, tt.errors)
+			}
+			for _, key := range tt.errors {
+				if _, ok := v.Errors[key]; !ok {
+					t.Errorf("no error for %q in %v", key, v.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMaturityLevel(t *testing.T) {
+	for level, want := range map[int]bool{-1: false, 0: true, 15: true, MaturityAdult: true, MaturityAdult + 1: false} {
+		v := validator.New()
+		ValidateMaturityLevel(v, level)
+		if v.Valid() != want {
+			t.Errorf("level %d: got valid %t; want %t", level, v.Valid(), want)
+		}
+	}
+}
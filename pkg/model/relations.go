@@ -0,0 +1,228 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrDuplicateRelation = errors.New("duplicate relation")
+)
+
+// RelationTypes lists the ways one dorama can be related to another. A relation reads
+// "dorama is a <type> of related", e.g. a remake of the original.
+var RelationTypes = []string{"sequel", "prequel", "remake", "spin_off", "adaptation"}
+
+// Relation is a typed, directed link between two doramas: DoramaID is a Relation of
+// RelatedID.
+type Relation struct {
+	DoramaID  int    `json:"dorama_id"`
+	RelatedID int    `json:"related_id"`
+	Relation  string `json:"relation"`
+}
+
+func ValidateRelation(v *validator.Validator, relation *Relation) {
+	v.Check(validator.In(relation.Relation, RelationTypes...), "relation", "must be one of sequel, prequel, remake, spin_off or adaptation")
+	v.Check(relation.DoramaID != relation.RelatedID, "related_id", "a dorama can't be related to itself")
+}
+
+// RelatedDorama is a dorama as seen from another one it is related to. Direction is
+// "outgoing" when the dorama we started from is a Relation of this one (so this is,
+// say, the original it remakes), and "incoming" when this one is a Relation of the
+// dorama we started from (say, a remake of it).
+type RelatedDorama struct {
+	DoramaID    int    `json:"dorama_id"`
+	Title       string `json:"title"`
+	ReleaseYear int    `json:"release_year"`
+	Relation    string `json:"relation"`
+	Direction   string `json:"direction"`
+}
+
+// Franchise is the connected group of doramas reachable from one dorama by following
+// relations in either direction.
+type Franchise struct {
+	Doramas   []*RelatedDorama `json:"doramas"`
+	Relations []*Relation      `json:"relations"`
+}
+
+// maxFranchiseSize caps how many doramas GetFranchise walks to and returns, in case the
+// relations have been used to link up much more than a single franchise.
+const maxFranchiseSize = 500
+
+// GetRelated returns the live doramas directly related to a dorama, in either direction,
+// leaving out any rated above maturityLevel.
+func (dm *DoramaModel) GetRelated(doramaID int, maturityLevel int) ([]*RelatedDorama, error) {
+	query := `
+		SELECT doramas.dorama_id, doramas.title, COALESCE(doramas.release_year, 0), dorama_relations.relation,
+			CASE WHEN dorama_relations.dorama_id = $1 THEN 'outgoing' ELSE 'incoming' END
+		FROM dorama_relations
+		INNER JOIN doramas ON doramas.dorama_id = CASE WHEN dorama_relations.dorama_id = $1 THEN dorama_relations.related_id ELSE dorama_relations.dorama_id END
+		WHERE (dorama_relations.dorama_id = $1 OR dorama_relations.related_id = $1)
+		AND doramas.deleted_at IS NULL
+		AND doramas.min_age <= $2
+		ORDER BY doramas.release_year NULLS LAST, doramas.dorama_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := dm.DB.QueryContext(ctx, query, doramaID, maturityLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	related := []*RelatedDorama{}
+	for rows.Next() {
+		var r RelatedDorama
+		err := rows.Scan(&r.DoramaID, &r.Title, &r.ReleaseYear, &r.Relation, &r.Direction)
+		if err != nil {
+			return nil, err
+		}
+		related = append(related, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return related, nil
+}
+
+// SetRelation adds a relation between two doramas, or changes its type if they are
+// already related the same way round. It returns ErrDuplicateRelation if they are
+// already related the other way round.
+func (dm *DoramaModel) SetRelation(relation *Relation) error {
+	query := `
+		INSERT INTO dorama_relations (dorama_id, related_id, relation)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (dorama_id, related_id)
+		DO UPDATE SET relation = EXCLUDED.relation`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := dm.DB.ExecContext(ctx, query, relation.DoramaID, relation.RelatedID, relation.Relation)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateRelation
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveRelation removes the relation between two doramas, whichever way round it was
+// recorded.
+func (dm *DoramaModel) RemoveRelation(doramaID, relatedID int) error {
+	query := `
+		DELETE FROM dorama_relations
+		WHERE (dorama_id = $1 AND related_id = $2) OR (dorama_id = $2 AND related_id = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := dm.DB.ExecContext(ctx, query, doramaID, relatedID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetFranchise walks the relation graph outwards from a dorama, in both directions, and
+// returns every live dorama it reaches together with the relations between them. The
+// Relation and Direction fields of the doramas are left empty, since they only make
+// sense relative to a single other dorama; use the relations for that. Doramas rated
+// above maturityLevel are neither returned nor walked through.
+func (dm *DoramaModel) GetFranchise(doramaID int, maturityLevel int) (*Franchise, error) {
+	// UNION rather than UNION ALL makes the recursion stop once no new doramas are
+	// found, so cycles in the graph are harmless. PostgreSQL only runs a recursive query
+	// for as long as its rows are being fetched, so taking the first maxFranchiseSize
+	// doramas before sorting them also stops the walk there rather than after the whole
+	// graph has been visited.
+	query := `
+		WITH RECURSIVE franchise (dorama_id) AS (
+			SELECT $1::integer
+			UNION
+			SELECT CASE WHEN dorama_relations.dorama_id = franchise.dorama_id THEN dorama_relations.related_id ELSE dorama_relations.dorama_id END
+			FROM dorama_relations
+			INNER JOIN franchise ON franchise.dorama_id IN (dorama_relations.dorama_id, dorama_relations.related_id)
+			INNER JOIN doramas ON doramas.dorama_id = CASE WHEN dorama_relations.dorama_id = franchise.dorama_id THEN dorama_relations.related_id ELSE dorama_relations.dorama_id END
+			WHERE doramas.deleted_at IS NULL AND doramas.min_age <= $3
+		)
+		SELECT doramas.dorama_id, doramas.title, COALESCE(doramas.release_year, 0)
+		FROM (SELECT dorama_id FROM franchise LIMIT $2) AS reached
+		INNER JOIN doramas ON doramas.dorama_id = reached.dorama_id
+		WHERE doramas.deleted_at IS NULL AND doramas.min_age <= $3
+		ORDER BY doramas.release_year NULLS LAST, doramas.dorama_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := dm.DB.QueryContext(ctx, query, doramaID, maxFranchiseSize, maturityLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	franchise := &Franchise{Doramas: []*RelatedDorama{}, Relations: []*Relation{}}
+	var ids []int64
+	for rows.Next() {
+		var d RelatedDorama
+		err := rows.Scan(&d.DoramaID, &d.Title, &d.ReleaseYear)
+		if err != nil {
+			return nil, err
+		}
+		franchise.Doramas = append(franchise.Doramas, &d)
+		ids = append(ids, int64(d.DoramaID))
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	query = `
+		SELECT dorama_id, related_id, relation
+		FROM dorama_relations
+		WHERE dorama_id = ANY($1) AND related_id = ANY($1)
+		ORDER BY dorama_id, related_id`
+
+	rows, err = dm.DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relation Relation
+		err := rows.Scan(&relation.DoramaID, &relation.RelatedID, &relation.Relation)
+		if err != nil {
+			return nil, err
+		}
+		franchise.Relations = append(franchise.Relations, &relation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return franchise, nil
+}
@@ -0,0 +1,248 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrDuplicateReview = errors.New("duplicate review")
+)
+
+// Review is a user's score for a dorama, from 1 to 10, with an optional text. Each user
+// can review a dorama once and edit the review afterwards. Hidden is set when a
+// moderator has hidden the review, which only its author still sees. Hidden, LikeCount
+// and CommentCount are derived and ignored on writes.
+type Review struct {
+	ID           int64     `json:"id"`
+	DoramaID     int       `json:"dorama_id"`
+	UserID       int64     `json:"user_id"`
+	UserName     string    `json:"user_name"`
+	Score        int       `json:"score"`
+	Body         string    `json:"body"`
+	Hidden       bool      `json:"hidden,omitempty"`
+	LikeCount    int       `json:"like_count"`
+	CommentCount int       `json:"comment_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Version      int       `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Score >= 1 && review.Score <= 10, "score", "must be between 1 and 10")
+	v.Check(len(review.Body) <= 20000, "body", "must not be more than 20000 bytes long")
+}
+
+type ReviewModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// reviewColumns are selected by every review query, with the names the sort safelist
+// refers to.
+const reviewColumns = `
+	reviews.id, reviews.dorama_id, reviews.user_id, users.name, reviews.score AS score, reviews.body,
+	reviews.hidden_at IS NOT NULL,
+	(SELECT count(*) FROM review_likes WHERE review_likes.review_id = reviews.id) AS like_count,
+	(SELECT count(*) FROM review_comments
+		WHERE review_comments.review_id = reviews.id AND review_comments.hidden_at IS NULL AND review_comments.deleted_at IS NULL),
+	reviews.created_at AS created_at, reviews.updated_at AS updated_at, reviews.version`
+
+// GetAllForDorama returns a page of the reviews of a dorama. Reviews hidden by a
+// moderator are left out.
+func (m ReviewModel) GetAllForDorama(doramaID int, filters Filters) ([]*Review, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM reviews
+		INNER JOIN users ON users.id = reviews.user_id
+		WHERE reviews.dorama_id = $1 AND reviews.hidden_at IS NULL
+		ORDER BY %s %s, id
+		LIMIT $2 OFFSET $3`,
+		reviewColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, doramaID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := []*Review{}
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(&totalRecords, &review.ID, &review.DoramaID, &review.UserID, &review.UserName, &review.Score, &review.Body,
+			&review.Hidden, &review.LikeCount, &review.CommentCount, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return reviews, metadata, nil
+}
+
+// Get returns a review of a dorama as userID sees it. Hidden reviews are reported as not
+// found to everyone but their author, who can still edit or withdraw them.
+func (m ReviewModel) Get(doramaID int, id int64, userID int64) (*Review, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM reviews
+		INNER JOIN users ON users.id = reviews.user_id
+		WHERE reviews.dorama_id = $1 AND reviews.id = $2 AND (reviews.hidden_at IS NULL OR reviews.user_id = $3)`,
+		reviewColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	review := &Review{}
+	err := m.DB.QueryRowContext(ctx, query, doramaID, id, userID).Scan(&review.ID, &review.DoramaID, &review.UserID, &review.UserName, &review.Score, &review.Body,
+		&review.Hidden, &review.LikeCount, &review.CommentCount, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return review, nil
+}
+
+// Insert adds a review, and returns ErrDuplicateReview if the user has already reviewed
+// the dorama.
+func (m ReviewModel) Insert(review *Review) error {
+	query := `
+		INSERT INTO reviews (dorama_id, user_id, score, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at, version`
+
+	args := []interface{}{review.DoramaID, review.UserID, review.Score, review.Body}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateReview
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Update saves the review only if the stored version still matches review.Version, and
+// returns ErrEditConflict otherwise.
+func (m ReviewModel) Update(review *Review) error {
+	query := `
+		UPDATE reviews
+		SET score = $1, body = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING updated_at, version`
+
+	args := []interface{}{review.Score, review.Body, review.ID, review.Version}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.UpdatedAt, &review.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ReviewModel) Delete(id int64) error {
+	query := `
+		DELETE FROM reviews
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Like records that a user likes a review. Liking a review twice has no further effect.
+func (m ReviewModel) Like(reviewID, userID int64) error {
+	query := `
+		INSERT INTO review_likes (review_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, reviewID, userID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Unlike takes back a user's like, and returns ErrRecordNotFound if they hadn't liked
+// the review.
+func (m ReviewModel) Unlike(reviewID, userID int64) error {
+	query := `
+		DELETE FROM review_likes
+		WHERE review_id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, reviewID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
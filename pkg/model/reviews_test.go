@@ -0,0 +1,113 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHiddenReviewStaysWithItsAuthor(t *testing.T) {
+	models := newTestModels(t)
+
+	dorama := insertTestDorama(t, models, &Dorama{})
+	author := insertTestUser(t, models, MaturityAdult)
+	reader := insertTestUser(t, models, MaturityAdult)
+
+	review := &Review{DoramaID: dorama.DoramaId, UserID: author.ID, Score: 3}
+	err := models.Reviews.Insert(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	action := &ModerationAction{TargetType: TargetReview, TargetID: review.ID, Action: ModerationHide}
+	err = models.Moderation.Moderate(action)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		models.Moderation.DB.Exec(`DELETE FROM moderation_actions WHERE id = $1`, action.ID)
+	})
+
+	_, err = models.Reviews.Get(dorama.DoramaId, review.ID, reader.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("reader: got %v; want ErrRecordNotFound", err)
+	}
+
+	got, err := models.Reviews.Get(dorama.DoramaId, review.ID, author.ID)
+	if err != nil {
+		t.Fatalf("author: %v", err)
+	}
+	if !got.Hidden {
+		t.Error("author: review not marked as hidden")
+	}
+
+	err = models.Reviews.Delete(got.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Reviews.Insert(&Review{DoramaID: dorama.DoramaId, UserID: author.ID, Score: 7})
+	if err != nil {
+		t.Errorf("reviewing again after withdrawing the hidden review: %v", err)
+	}
+}
+
+func TestDeleteCommentKeepsReplies(t *testing.T) {
+	models := newTestModels(t)
+
+	dorama := insertTestDorama(t, models, &Dorama{})
+	user := insertTestUser(t, models, MaturityAdult)
+
+	review := &Review{DoramaID: dorama.DoramaId, UserID: user.ID, Score: 8}
+	err := models.Reviews.Insert(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := &Comment{ReviewID: review.ID, UserID: user.ID, Body: "Parent"}
+	err = models.Comments.Insert(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reply := &Comment{ReviewID: review.ID, ParentID: parent.ID, UserID: user.ID, Body: "Reply"}
+	err = models.Comments.Insert(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Comments.Delete(parent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := Filters{Page: 1, PageSize: 20, Sort: "created_at", SortSafelist: []string{"created_at"}}
+	comments, _, err := models.Comments.GetAllForReview(review.ID, filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(comments) != 1 {
+		t.Fatalf("got %d comments; want the blanked parent", len(comments))
+	}
+	if !comments[0].Deleted || comments[0].Body != "" {
+		t.Errorf("got deleted %t and body %q; want a blanked comment", comments[0].Deleted, comments[0].Body)
+	}
+	if len(comments[0].Replies) != 1 || comments[0].Replies[0].ID != reply.ID {
+		t.Errorf("got %d replies; want the reply to be kept", len(comments[0].Replies))
+	}
+
+	err = models.Comments.Delete(parent.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("deleting twice: got %v; want ErrRecordNotFound", err)
+	}
+
+	err = models.Comments.Delete(reply.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.Comments.Get(review.ID, reply.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("reply: got %v; want ErrRecordNotFound", err)
+	}
+}
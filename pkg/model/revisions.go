@@ -0,0 +1,234 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Define constants for the kinds of change a revision can record.
+const (
+	RevisionInsert  = "insert"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+// Revision is a snapshot of a single change to a catalog record. Before and After hold
+// the JSON representation of the record on either side of the change; Before is null
+// for inserts and restores, After is null for deletes. The revision number is the
+// record's version once the change was applied.
+type Revision struct {
+	ID         int64           `json:"id"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Revision   int             `json:"revision"`
+	Action     string          `json:"action"`
+	UserID     int64           `json:"user_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+}
+
+// State returns the record as it stood once this revision was applied. For a delete,
+// that is the record as it was just before it went to the trash.
+func (r *Revision) State() json.RawMessage {
+	if r.After != nil {
+		return r.After
+	}
+	return r.Before
+}
+
+// FieldChange is a single entry in the diff between two revisions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// derivedFields lists, by entity type, the fields that revisions recorded before their
+// snapshots were limited to the editable fields still carry. They change without
+// anyone editing the record, so DiffRevisions leaves them out.
+var derivedFields = map[string][]string{
+	"dorama": {
+		"dorama_id", "poster_url", "poster_thumbnail_url", "min_age", "episode_count",
+		"total_runtime", "rating", "rating_count", "version", "language", "matched_alias",
+	},
+}
+
+// DiffRevisions compares the state of a record at two revisions field by field, and
+// returns the fields that differ in alphabetical order.
+func DiffRevisions(from, to *Revision) ([]*FieldChange, error) {
+	var fromFields, toFields map[string]interface{}
+
+	err := json.Unmarshal(from.State(), &fromFields)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(to.State(), &toFields)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool)
+	for field := range fromFields {
+		fields[field] = true
+	}
+	for field := range toFields {
+		fields[field] = true
+	}
+	for _, field := range derivedFields[to.EntityType] {
+		delete(fields, field)
+	}
+
+	changes := []*FieldChange{}
+	for field := range fields {
+		if !reflect.DeepEqual(fromFields[field], toFields[field]) {
+			changes = append(changes, &FieldChange{Field: field, From: fromFields[field], To: toFields[field]})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+type RevisionModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// GetAllForEntity returns a page of the revisions of a single record, without their
+// snapshots, which can be large. Use Get to fetch a revision in full. The metadata
+// counts the whole history even when the page is past its end, so that an empty page
+// can be told apart from a record with no history.
+func (m RevisionModel) GetAllForEntity(entityType string, entityID int, filters Filters) ([]*Revision, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, entity_type, entity_id, revision, action, COALESCE(user_id, 0), created_at
+		FROM revisions
+		WHERE entity_type = $1 AND entity_id = $2
+		ORDER BY %s %s, id
+		LIMIT $3 OFFSET $4`,
+		filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, entityType, entityID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	revisions := []*Revision{}
+	for rows.Next() {
+		var revision Revision
+		err := rows.Scan(&totalRecords, &revision.ID, &revision.EntityType, &revision.EntityID, &revision.Revision, &revision.Action, &revision.UserID, &revision.CreatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if len(revisions) == 0 && filters.Page > 1 {
+		query = `SELECT count(*) FROM revisions WHERE entity_type = $1 AND entity_id = $2`
+		err = m.DB.QueryRowContext(ctx, query, entityType, entityID).Scan(&totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return revisions, metadata, nil
+}
+
+func (m RevisionModel) Get(entityType string, entityID, revisionNumber int) (*Revision, error) {
+	query := `
+		SELECT id, entity_type, entity_id, revision, action, COALESCE(user_id, 0), created_at, before, after
+		FROM revisions
+		WHERE entity_type = $1 AND entity_id = $2 AND revision = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var revision Revision
+	var before, after []byte
+	err := m.DB.QueryRowContext(ctx, query, entityType, entityID, revisionNumber).Scan(
+		&revision.ID,
+		&revision.EntityType,
+		&revision.EntityID,
+		&revision.Revision,
+		&revision.Action,
+		&revision.UserID,
+		&revision.CreatedAt,
+		&before,
+		&after,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	revision.Before = before
+	revision.After = after
+	return &revision, nil
+}
+
+// recordRevision writes a revision row as part of the caller's transaction, so that a
+// change and its history entry are committed (or rolled back) together. Pass nil for
+// before or after when there is no record on that side of the change.
+func recordRevision(ctx context.Context, tx *sql.Tx, entityType string, entityID, revision int, action string, userID int64, before, after interface{}) error {
+	beforeJSON, err := marshalSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := marshalSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO revisions (entity_type, entity_id, revision, action, user_id, before, after)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7)`
+
+	_, err = tx.ExecContext(ctx, query, entityType, entityID, revision, action, userID, beforeJSON, afterJSON)
+	return err
+}
+
+// marshalSnapshot encodes a record for storage in a jsonb column. A nil interface or a
+// nil pointer is stored as SQL NULL.
+func marshalSnapshot(record interface{}) (sql.NullString, error) {
+	if record == nil {
+		return sql.NullString{}, nil
+	}
+	if v := reflect.ValueOf(record); v.Kind() == reflect.Ptr && v.IsNil() {
+		return sql.NullString{}, nil
+	}
+
+	js, err := json.Marshal(record)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(js), Valid: true}, nil
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffRevisions(t *testing.T) {
+	revision := func(before, after string) *Revision {
+		r := &Revision{}
+		if before != "" {
+			r.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			r.After = json.RawMessage(after)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		from, to *Revision
+		want     []*FieldChange
+	}{
+		{
+			name: "no change",
+			from: revision("", `{"title": "Goblin", "year": 2016}`),
+			to:   revision(`{"title": "Goblin", "year": 2016}`, `{"year": 2016, "title": "Goblin"}`),
+			want: []*FieldChange{},
+		},
+		{
+			name: "changed fields in order",
+			from: revision("", `{"year": 2016, "title": "Goblin", "status": "airing"}`),
+			to:   revision("", `{"year": 2017, "title": "Guardian", "status": "airing"}`),
+			want: []*FieldChange{
+				{Field: "title", From: "Goblin", To: "Guardian"},
+				{Field: "year", From: float64(2016), To: float64(2017)},
+			},
+		},
+		{
+			name: "added and removed fields",
+			from: revision("", `{"title": "Goblin", "poster_url": "a.jpg"}`),
+			to:   revision("", `{"title": "Goblin", "end_date": "2017-01-21"}`),
+			want: []*FieldChange{
+				{Field: "end_date", From: nil, To: "2017-01-21"},
+				{Field: "poster_url", From: "a.jpg", To: nil},
+			},
+		},
+		{
+			name: "nested values",
+			from: revision("", `{"credits": [{"actor_id": 1}]}`),
+			to:   revision("", `{"credits": [{"actor_id": 1}, {"actor_id": 2}]}`),
+			want: []*FieldChange{
+				{Field: "credits", From: []interface{}{map[string]interface{}{"actor_id": float64(1)}}, To: []interface{}{map[string]interface{}{"actor_id": float64(1)}, map[string]interface{}{"actor_id": float64(2)}}},
+			},
+		},
+		{
+			name: "derived dorama fields",
+			from: &Revision{EntityType: "dorama", After: json.RawMessage(`{"title": "Goblin", "rating": 8.1, "version": 3, "poster_url": "a.jpg"}`)},
+			to:   &Revision{EntityType: "dorama", After: json.RawMessage(`{"title": "Guardian", "rating": 8.4, "version": 5}`)},
+			want: []*FieldChange{
+				{Field: "title", From: "Goblin", To: "Guardian"},
+			},
+		},
+		{
+			name: "deleted record compares its last state",
+			from: revision("", `{"title": "Goblin"}`),
+			to:   revision(`{"title": "Goblin"}`, ""),
+			want: []*FieldChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffRevisions(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(tt.want)
+				t.Errorf("got %s; want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+
+	t.Run("malformed state", func(t *testing.T) {
+		_, err := DiffRevisions(revision("", `{"title":`), revision("", `{}`))
+		if err == nil {
+			t.Error("got no error")
+		}
+	})
+}
+
+func TestDoramaSnapshotLeavesOutDerivedFields(t *testing.T) {
+	models := newTestModels(t)
+
+	dorama := insertTestDorama(t, models, &Dorama{RatingSystem: "KMRB", ContentRating: "15"})
+	t.Cleanup(func() {
+		models.Revisions.DB.Exec(`DELETE FROM revisions WHERE entity_type = 'dorama' AND entity_id = $1`, dorama.DoramaId)
+	})
+
+	revision, err := models.Revisions.Get("dorama", dorama.DoramaId, dorama.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(revision.After, &fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range derivedFields["dorama"] {
+		if _, found := fields[field]; found {
+			t.Errorf("snapshot carries the derived field %q", field)
+		}
+	}
+	for _, field := range []string{"title", "content_rating", "credits"} {
+		if _, found := fields[field]; !found {
+			t.Errorf("snapshot doesn't carry %q", field)
+		}
+	}
+}
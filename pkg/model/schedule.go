@@ -0,0 +1,237 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrDuplicateBroadcastSlot = errors.New("duplicate broadcast slot")
+)
+
+// AirTimeRX matches a time of day in 24-hour HH:MM format.
+var AirTimeRX = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// BroadcastSlot is a weekly time at which a dorama airs. Weekday uses ISO numbering, so
+// 1 is Monday and 7 is Sunday; AirTime is local to Timezone.
+type BroadcastSlot struct {
+	ID       int    `json:"id"`
+	DoramaID int    `json:"dorama_id"`
+	Weekday  int    `json:"weekday"`
+	AirTime  string `json:"air_time"`
+	Timezone string `json:"timezone"`
+}
+
+func ValidateBroadcastSlot(v *validator.Validator, slot *BroadcastSlot) {
+	v.Check(slot.Weekday >= 1 && slot.Weekday <= 7, "weekday", "must be between 1 (Monday) and 7 (Sunday)")
+	v.Check(validator.Matches(slot.AirTime, AirTimeRX), "air_time", "must be a time in HH:MM format")
+	_, err := time.LoadLocation(slot.Timezone)
+	v.Check(slot.Timezone != "" && err == nil, "timezone", "must be a valid IANA time zone")
+}
+
+// ScheduleEntry is a single airing of a dorama on a given date, as listed in the
+// broadcast schedule.
+type ScheduleEntry struct {
+	DoramaID int    `json:"dorama_id"`
+	Title    string `json:"title"`
+	Status   string `json:"status"`
+	AirDate  string `json:"air_date"`
+	AirTime  string `json:"air_time"`
+	Timezone string `json:"timezone"`
+}
+
+type ScheduleModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+func (m ScheduleModel) GetAllForDorama(doramaID int) ([]*BroadcastSlot, error) {
+	query := `
+		SELECT id, dorama_id, weekday, to_char(air_time, 'HH24:MI'), timezone
+		FROM broadcast_slots
+		WHERE dorama_id = $1
+		ORDER BY weekday, air_time`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, doramaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := []*BroadcastSlot{}
+	for rows.Next() {
+		var slot BroadcastSlot
+		err := rows.Scan(&slot.ID, &slot.DoramaID, &slot.Weekday, &slot.AirTime, &slot.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, &slot)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+func (m ScheduleModel) Get(doramaID, id int) (*BroadcastSlot, error) {
+	query := `
+		SELECT id, dorama_id, weekday, to_char(air_time, 'HH24:MI'), timezone
+		FROM broadcast_slots
+		WHERE dorama_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	slot := &BroadcastSlot{}
+	err := m.DB.QueryRowContext(ctx, query, doramaID, id).Scan(&slot.ID, &slot.DoramaID, &slot.Weekday, &slot.AirTime, &slot.Timezone)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return slot, nil
+}
+
+func (m ScheduleModel) Insert(slot *BroadcastSlot) error {
+	query := `
+		INSERT INTO broadcast_slots (dorama_id, weekday, air_time, timezone)
+		VALUES ($1, $2, $3::time, $4)
+		RETURNING id`
+
+	args := []interface{}{slot.DoramaID, slot.Weekday, slot.AirTime, slot.Timezone}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&slot.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateBroadcastSlot
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ScheduleModel) Update(slot *BroadcastSlot) error {
+	query := `
+		UPDATE broadcast_slots
+		SET weekday = $1, air_time = $2::time, timezone = $3
+		WHERE id = $4 AND dorama_id = $5
+		RETURNING id`
+
+	args := []interface{}{slot.Weekday, slot.AirTime, slot.Timezone, slot.ID, slot.DoramaID}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&slot.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateBroadcastSlot
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ScheduleModel) Delete(doramaID, id int) error {
+	query := `
+		DELETE FROM broadcast_slots
+		WHERE dorama_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, doramaID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetSchedule expands the weekly broadcast slots of airing and announced doramas into
+// individual airings between from and to (inclusive, both YYYY-MM-DD). An airing is
+// only listed on dates that fall within the dorama's start and end dates; announced
+// doramas without a start date have nothing to show yet. Doramas rated above
+// maturityLevel are left out.
+func (m ScheduleModel) GetSchedule(from, to string, maturityLevel int, filters Filters) ([]*ScheduleEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), doramas.dorama_id, doramas.title, doramas.status,
+			to_char(day, 'YYYY-MM-DD') AS air_date, to_char(broadcast_slots.air_time, 'HH24:MI'), broadcast_slots.timezone
+		FROM broadcast_slots
+		INNER JOIN doramas ON doramas.dorama_id = broadcast_slots.dorama_id
+		CROSS JOIN generate_series($1::date, $2::date, interval '1 day') AS day
+		WHERE doramas.deleted_at IS NULL
+		AND doramas.status IN ('airing', 'announced')
+		AND doramas.min_age <= $5
+		AND EXTRACT(ISODOW FROM day) = broadcast_slots.weekday
+		AND (doramas.start_date <= day OR (doramas.start_date IS NULL AND doramas.status = 'airing'))
+		AND (doramas.end_date IS NULL OR day <= doramas.end_date)
+		ORDER BY %s %s, broadcast_slots.air_time, doramas.dorama_id
+		LIMIT $3 OFFSET $4`,
+		filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, from, to, filters.limit(), filters.offset(), maturityLevel)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	entries := []*ScheduleEntry{}
+	for rows.Next() {
+		var entry ScheduleEntry
+		err := rows.Scan(&totalRecords, &entry.DoramaID, &entry.Title, &entry.Status, &entry.AirDate, &entry.AirTime, &entry.Timezone)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return entries, metadata, nil
+}
@@ -0,0 +1,169 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+var (
+	ErrDuplicateSeason = errors.New("duplicate season")
+)
+
+// Season is one season of a dorama. Seasons are addressed by their number within the
+// dorama rather than by their ID, so that /doramas/1/seasons/2 means "season 2".
+type Season struct {
+	ID           int    `json:"id"`
+	DoramaID     int    `json:"dorama_id"`
+	Number       int    `json:"season_number"`
+	Title        string `json:"title"`
+	AirDate      string `json:"air_date,omitempty"`
+	Synopsis     string `json:"synopsis"`
+	EpisodeCount int    `json:"episode_count"`
+}
+
+func ValidateSeason(v *validator.Validator, season *Season) {
+	v.Check(season.Number > 0, "season_number", "must be greater than zero")
+	v.Check(len(season.Title) <= 255, "title", "must not be more than 255 bytes long")
+	v.Check(season.AirDate == "" || validator.Date(season.AirDate), "air_date", "must be a date in YYYY-MM-DD format")
+}
+
+type SeasonModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+func (sm *SeasonModel) GetAllForDorama(doramaID int) ([]*Season, error) {
+	query := `
+		SELECT id, dorama_id, season_number, title, COALESCE(to_char(air_date, 'YYYY-MM-DD'), ''), synopsis,
+			(SELECT count(*) FROM episodes WHERE episodes.dorama_id = seasons.dorama_id AND episodes.season_number = seasons.season_number)
+		FROM seasons
+		WHERE dorama_id = $1
+		ORDER BY season_number`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := sm.DB.QueryContext(ctx, query, doramaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seasons := []*Season{}
+	for rows.Next() {
+		var season Season
+		err := rows.Scan(&season.ID, &season.DoramaID, &season.Number, &season.Title, &season.AirDate, &season.Synopsis, &season.EpisodeCount)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, &season)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return seasons, nil
+}
+
+func (sm *SeasonModel) Get(doramaID, number int) (*Season, error) {
+	query := `
+		SELECT id, dorama_id, season_number, title, COALESCE(to_char(air_date, 'YYYY-MM-DD'), ''), synopsis,
+			(SELECT count(*) FROM episodes WHERE episodes.dorama_id = seasons.dorama_id AND episodes.season_number = seasons.season_number)
+		FROM seasons
+		WHERE dorama_id = $1 AND season_number = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	season := &Season{}
+	err := sm.DB.QueryRowContext(ctx, query, doramaID, number).Scan(&season.ID, &season.DoramaID, &season.Number, &season.Title, &season.AirDate, &season.Synopsis, &season.EpisodeCount)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return season, nil
+}
+
+func (sm *SeasonModel) Insert(season *Season) error {
+	query := `
+		INSERT INTO seasons (dorama_id, season_number, title, air_date, synopsis)
+		VALUES ($1, $2, $3, NULLIF($4, '')::date, $5)
+		RETURNING id`
+
+	args := []interface{}{season.DoramaID, season.Number, season.Title, season.AirDate, season.Synopsis}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := sm.DB.QueryRowContext(ctx, query, args...).Scan(&season.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateSeason
+		}
+		return err
+	}
+	return nil
+}
+
+// Update saves the season. Changing the season number renumbers its episodes too,
+// through the ON UPDATE CASCADE foreign key on the episodes table.
+func (sm *SeasonModel) Update(season *Season) error {
+	query := `
+		UPDATE seasons
+		SET season_number = $1, title = $2, air_date = NULLIF($3, '')::date, synopsis = $4
+		WHERE id = $5
+		RETURNING id`
+
+	args := []interface{}{season.Number, season.Title, season.AirDate, season.Synopsis, season.ID}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := sm.DB.QueryRowContext(ctx, query, args...).Scan(&season.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateSeason
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a season and, through the foreign key, all of its episodes.
+func (sm *SeasonModel) Delete(doramaID, number int) error {
+	query := `
+		DELETE FROM seasons
+		WHERE dorama_id = $1 AND season_number = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := sm.DB.ExecContext(ctx, query, doramaID, number)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -15,6 +15,8 @@ import (
 const (
 	ScopeActivation = "activation"
 	ScopeAuthentication = "authentication"
+	ScopeEmailChange = "email_change"
+	ScopePasswordReset = "password_reset"
 )
 
 // Define a Token struct to hold the data for an individual token. This includes the
@@ -26,6 +28,25 @@ type Token struct {
 	UserID    int64  `json:"-"`
 	Expiry time.Time `json:"expiry"`
 	Scope     string `json:"-"`
+	// ID and CreatedAt are set by Insert. UserAgent and IP describe the client the
+	// token was issued to, and are only recorded for authentication tokens.
+	ID        int64     `json:"-"`
+	CreatedAt time.Time `json:"-"`
+	UserAgent string    `json:"-"`
+	IP        string    `json:"-"`
+}
+
+// Session is an authentication token as its owner sees it when managing where they are
+// signed in. It never includes the token itself.
+type Session struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Expiry     time.Time `json:"expiry"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	// Current is true for the session the request was made with.
+	Current bool `json:"current"`
 }
 	
 
@@ -95,20 +116,194 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token,
 	return token, err
 }
 
+// NewWithEmail creates a token like New, and queues the email that delivers it in the
+// same transaction, so that there is never a token without its email or the other way
+// round. The plaintext token is added to the email data under "token".
+func (m TokenModel) NewWithEmail(userID int64, ttl time.Duration, scope string, email *OutboxEmail) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	token, err := newTokenWithEmail(ctx, tx, userID, ttl, scope, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, tx.Commit()
+}
+
+// newTokenWithEmail creates a token and queues the email that delivers it as part of
+// tx, for the callers that have more to change in the same transaction.
+func newTokenWithEmail(ctx context.Context, tx *sql.Tx, userID int64, ttl time.Duration, scope string, email *OutboxEmail) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if email.Data == nil {
+		email.Data = make(map[string]interface{})
+	}
+	email.Data["token"] = token.Plaintext
+
+	err = insertToken(ctx, tx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = enqueueEmail(ctx, tx, email)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// NewSession creates an authentication token for a client that just signed in,
+// recording its user agent and IP address so that the user can tell their sessions
+// apart.
+func (m TokenModel) NewSession(userID int64, ttl time.Duration, userAgent, ip string) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	token.UserAgent = userAgent
+	token.IP = ip
+
+	err = m.Insert(token)
+	return token, err
+}
+
 // Insert() adds the data for a specific token to the tokens table.
 func (m TokenModel) Insert(token *Token) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return insertToken(ctx, m.DB, token)
+}
+
+// insertToken adds a token with q, which is either the connection pool or a
+// transaction.
+func insertToken(ctx context.Context, q queryer, token *Token) error {
 	query := `
-	INSERT INTO tokens (hash, user_id, expiry, scope)
-	VALUES ($1, $2, $3, $4)`
-	
-	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope}
+	INSERT INTO tokens (hash, user_id, expiry, scope, user_agent, ip)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`
+
+	args := []interface{}{token.Hash, token.UserID, token.Expiry, token.Scope, token.UserAgent, token.IP}
+	return q.QueryRowContext(ctx, query, args...).Scan(&token.ID, &token.CreatedAt)
+}
+
+// Touch records that an authentication token was just used. To save a write on every
+// request, the time is only moved on once it is more than a minute old.
+func (m TokenModel) Touch(tokenPlaintext string) error {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+	UPDATE tokens
+	SET last_used_at = NOW()
+	WHERE hash = $1 AND last_used_at < NOW() - interval '1 minute'`
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	_, err := m.DB.ExecContext(ctx, query, args...)
-	
+	_, err := m.DB.ExecContext(ctx, query, tokenHash[:])
+	return err
+}
+
+// GetSessionsForUser returns the unexpired authentication tokens of a user, most
+// recently used first. currentToken is the plaintext token of the request, and marks
+// that session as current.
+func (m TokenModel) GetSessionsForUser(userID int64, currentToken string) ([]*Session, error) {
+	currentHash := sha256.Sum256([]byte(currentToken))
+
+	query := `
+	SELECT id, created_at, last_used_at, expiry, user_agent, ip, hash = $3
+	FROM tokens
+	WHERE user_id = $1 AND scope = $2 AND expiry > NOW()
+	ORDER BY last_used_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication, currentHash[:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		var session Session
+		err := rows.Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt, &session.Expiry, &session.UserAgent, &session.IP, &session.Current)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteSession revokes one of a user's authentication tokens, and returns
+// ErrRecordNotFound if the user has no session with that id.
+func (m TokenModel) DeleteSession(userID, id int64) error {
+	query := `
+	DELETE FROM tokens
+	WHERE id = $1 AND user_id = $2 AND scope = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID, ScopeAuthentication)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteByPlaintext revokes the token with the given plaintext in the given scope.
+func (m TokenModel) DeleteByPlaintext(scope, tokenPlaintext string) error {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+	DELETE FROM tokens
+	WHERE hash = $1 AND scope = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, tokenHash[:], scope)
 	return err
 }
 	
+// DeleteOtherSessions revokes every authentication token of a user except the one the
+// request was made with, so that a password change signs out everywhere else.
+func (m TokenModel) DeleteOtherSessions(userID int64, currentToken string) error {
+	currentHash := sha256.Sum256([]byte(currentToken))
+
+	query := `
+	DELETE FROM tokens
+	WHERE user_id = $1 AND scope = $2 AND hash <> $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err := m.DB.ExecContext(ctx, query, userID, ScopeAuthentication, currentHash[:])
+	return err
+}
+
 // DeleteAllForUser() deletes all tokens for a specific user and scope.
 func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
 	query := `
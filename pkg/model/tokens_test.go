@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUpdateWithTokenReplacesEarlierTokens(t *testing.T) {
+	models := newTestModels(t)
+	user := insertTestUser(t, models, MaturityAdult)
+
+	user.PendingEmail = "first-" + user.Email
+	first, err := models.Users.UpdateWithToken(user, time.Hour, ScopeEmailChange, &OutboxEmail{Recipient: user.PendingEmail, Template: "email_change.tmpl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user.PendingEmail = "second-" + user.Email
+	second, err := models.Users.UpdateWithToken(user, time.Hour, ScopeEmailChange, &OutboxEmail{Recipient: user.PendingEmail, Template: "email_change.tmpl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		models.Outbox.DB.Exec(`DELETE FROM email_outbox WHERE recipient IN ($1, $2)`, "first-"+user.Email, "second-"+user.Email)
+	})
+
+	_, err = models.Users.GetForToken(ScopeEmailChange, first.Plaintext)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("first token: got %v; want ErrRecordNotFound", err)
+	}
+
+	got, err := models.Users.GetForToken(ScopeEmailChange, second.Plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PendingEmail != user.PendingEmail {
+		t.Errorf("got pending email %q; want %q", got.PendingEmail, user.PendingEmail)
+	}
+
+	// A stale version must leave both the user and the tokens alone.
+	user.Version--
+	_, err = models.Users.UpdateWithToken(user, time.Hour, ScopeEmailChange, &OutboxEmail{Recipient: "third-" + user.Email, Template: "email_change.tmpl"})
+	if !errors.Is(err, ErrEditConflict) {
+		t.Errorf("stale version: got %v; want ErrEditConflict", err)
+	}
+
+	_, err = models.Users.GetForToken(ScopeEmailChange, second.Plaintext)
+	if err != nil {
+		t.Errorf("second token after a failed update: %v", err)
+	}
+}
+
+func TestDeleteOtherSessions(t *testing.T) {
+	models := newTestModels(t)
+	user := insertTestUser(t, models, MaturityAdult)
+
+	current, err := models.Tokens.NewSession(user.ID, time.Hour, "current", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := models.Tokens.NewSession(user.ID, time.Hour, "other", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Tokens.DeleteOtherSessions(user.ID, current.Plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = models.Users.GetForToken(ScopeAuthentication, current.Plaintext)
+	if err != nil {
+		t.Errorf("current session: %v", err)
+	}
+	_, err = models.Users.GetForToken(ScopeAuthentication, other.Plaintext)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("other session: got %v; want ErrRecordNotFound", err)
+	}
+}
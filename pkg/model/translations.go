@@ -0,0 +1,314 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// LanguageRX matches the lowercase BCP 47 style tags that translations are stored
+// under, such as "ko", "en" or "zh-hant".
+var LanguageRX = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// DoramaTranslation holds a dorama's title and description in a single language.
+type DoramaTranslation struct {
+	DoramaID    int    `json:"dorama_id"`
+	Language    string `json:"language"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func ValidateDoramaTranslation(v *validator.Validator, translation *DoramaTranslation) {
+	v.Check(validator.Matches(translation.Language, LanguageRX), "language", "must be a valid language tag")
+	v.Check(translation.Title != "", "title", "must be provided")
+	v.Check(len(translation.Title) <= 255, "title", "must not be more than 255 bytes long")
+}
+
+// GenreTranslation holds a genre's name in a single language.
+type GenreTranslation struct {
+	GenreID   int    `json:"genre_id"`
+	Language  string `json:"language"`
+	GenreName string `json:"genre_name"`
+}
+
+func ValidateGenreTranslation(v *validator.Validator, translation *GenreTranslation) {
+	v.Check(validator.Matches(translation.Language, LanguageRX), "language", "must be a valid language tag")
+	v.Check(translation.GenreName != "", "genre_name", "must be provided")
+	v.Check(len(translation.GenreName) <= 255, "genre_name", "must not be more than 255 bytes long")
+}
+
+type TranslationModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+func (m TranslationModel) GetAllForDorama(doramaID int) ([]*DoramaTranslation, error) {
+	query := `
+		SELECT dorama_id, language, title, description
+		FROM dorama_translations
+		WHERE dorama_id = $1
+		ORDER BY language`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, doramaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := []*DoramaTranslation{}
+	for rows.Next() {
+		var translation DoramaTranslation
+		err := rows.Scan(&translation.DoramaID, &translation.Language, &translation.Title, &translation.Description)
+		if err != nil {
+			return nil, err
+		}
+		translations = append(translations, &translation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
+
+// SetDoramaTranslation adds a translation, or replaces the existing one for the same
+// language. It returns ErrRecordNotFound if the dorama doesn't exist.
+func (m TranslationModel) SetDoramaTranslation(translation *DoramaTranslation) error {
+	query := `
+		INSERT INTO dorama_translations (dorama_id, language, title, description)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (dorama_id, language)
+		DO UPDATE SET title = EXCLUDED.title, description = EXCLUDED.description`
+
+	args := []interface{}{translation.DoramaID, translation.Language, translation.Title, translation.Description}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m TranslationModel) DeleteDoramaTranslation(doramaID int, language string) error {
+	query := `
+		DELETE FROM dorama_translations
+		WHERE dorama_id = $1 AND language = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, doramaID, language)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m TranslationModel) GetAllForGenre(genreID int) ([]*GenreTranslation, error) {
+	query := `
+		SELECT genre_id, language, genre_name
+		FROM genre_translations
+		WHERE genre_id = $1
+		ORDER BY language`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, genreID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	translations := []*GenreTranslation{}
+	for rows.Next() {
+		var translation GenreTranslation
+		err := rows.Scan(&translation.GenreID, &translation.Language, &translation.GenreName)
+		if err != nil {
+			return nil, err
+		}
+		translations = append(translations, &translation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
+
+// SetGenreTranslation adds a translation, or replaces the existing one for the same
+// language. It returns ErrRecordNotFound if the genre doesn't exist.
+func (m TranslationModel) SetGenreTranslation(translation *GenreTranslation) error {
+	query := `
+		INSERT INTO genre_translations (genre_id, language, genre_name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (genre_id, language)
+		DO UPDATE SET genre_name = EXCLUDED.genre_name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, translation.GenreID, translation.Language, translation.GenreName)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m TranslationModel) DeleteGenreTranslation(genreID int, language string) error {
+	query := `
+		DELETE FROM genre_translations
+		WHERE genre_id = $1 AND language = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, genreID, language)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// LocalizeDoramas replaces the title and description of each dorama with its
+// translation in the first of the given languages that has one, and sets Language
+// accordingly. Doramas with no translation in any of the languages are left as stored.
+func (m TranslationModel) LocalizeDoramas(doramas []*Dorama, languages []string) error {
+	if len(doramas) == 0 || len(languages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(doramas))
+	for i, dorama := range doramas {
+		ids[i] = int64(dorama.DoramaId)
+	}
+
+	// Pick the best translation per dorama in SQL, ranking languages by their position
+	// in the preference list.
+	query := `
+		SELECT DISTINCT ON (dorama_id) dorama_id, language, title, description
+		FROM dorama_translations
+		WHERE dorama_id = ANY($1) AND language = ANY($2)
+		ORDER BY dorama_id, array_position($2, language)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), pq.Array(languages))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	translations := make(map[int]*DoramaTranslation)
+	for rows.Next() {
+		var translation DoramaTranslation
+		err := rows.Scan(&translation.DoramaID, &translation.Language, &translation.Title, &translation.Description)
+		if err != nil {
+			return err
+		}
+		translations[translation.DoramaID] = &translation
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, dorama := range doramas {
+		if translation, ok := translations[dorama.DoramaId]; ok {
+			dorama.Title = translation.Title
+			dorama.Description = translation.Description
+			dorama.Language = translation.Language
+		}
+	}
+	return nil
+}
+
+// LocalizeGenres does the same as LocalizeDoramas for genre names.
+func (m TranslationModel) LocalizeGenres(genres []*Genre, languages []string) error {
+	if len(genres) == 0 || len(languages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(genres))
+	for i, genre := range genres {
+		ids[i] = int64(genre.GenreID)
+	}
+
+	query := `
+		SELECT DISTINCT ON (genre_id) genre_id, language, genre_name
+		FROM genre_translations
+		WHERE genre_id = ANY($1) AND language = ANY($2)
+		ORDER BY genre_id, array_position($2, language)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(ids), pq.Array(languages))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	translations := make(map[int]*GenreTranslation)
+	for rows.Next() {
+		var translation GenreTranslation
+		err := rows.Scan(&translation.GenreID, &translation.Language, &translation.GenreName)
+		if err != nil {
+			return err
+		}
+		translations[translation.GenreID] = &translation
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, genre := range genres {
+		if translation, ok := translations[genre.GenreID]; ok {
+			genre.GenreName = translation.GenreName
+			genre.Language = translation.Language
+		}
+	}
+	return nil
+}
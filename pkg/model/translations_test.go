@@ -0,0 +1,47 @@
+package model
+
+import "testing"
+
+func TestLocalizeDoramasAfterUpdate(t *testing.T) {
+	models := newTestModels(t)
+	dorama := insertTestDorama(t, models, &Dorama{})
+
+	dorama.Title = dorama.Title + " (updated)"
+	dorama.Description = "Updated description"
+	err := models.Doramas.Update(dorama, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := models.Doramas.Get(dorama.DoramaId, MaturityAdult)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Translations.LocalizeDoramas([]*Dorama{got}, []string{"en", "ru"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != dorama.Title || got.Description != dorama.Description {
+		t.Errorf("got %q, %q; want %q, %q", got.Title, got.Description, dorama.Title, dorama.Description)
+	}
+	if got.Language != "" {
+		t.Errorf("got language %q; want the dorama's own fields", got.Language)
+	}
+
+	translation := &DoramaTranslation{DoramaID: dorama.DoramaId, Language: "en", Title: "English title"}
+	err = models.Translations.SetDoramaTranslation(translation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Translations.LocalizeDoramas([]*Dorama{got}, []string{"en", "ru"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Title != "English title" || got.Language != "en" {
+		t.Errorf("got %q in %q; want the en translation", got.Title, got.Language)
+	}
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// TrashItem is a soft-deleted dorama, actor or genre, as shown in the admin trash
+// listing. Type is one of "dorama", "actor" or "genre".
+type TrashItem struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// TrashModel works across the catalog tables that support soft deletes. Restoring a
+// single record is left to the model that owns it.
+type TrashModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// GetAll returns a page of trashed records, optionally narrowed down to a single type.
+func (m TrashModel) GetAll(itemType string, filters Filters) ([]*TrashItem, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), type, id, name, deleted_at
+		FROM (
+			SELECT 'dorama' AS type, dorama_id AS id, title AS name, deleted_at FROM doramas WHERE deleted_at IS NOT NULL
+			UNION ALL
+			SELECT 'actor', id, full_name, deleted_at FROM actors WHERE deleted_at IS NOT NULL
+			UNION ALL
+			SELECT 'genre', genre_id, genre_name, deleted_at FROM genres WHERE deleted_at IS NOT NULL
+		) AS trash
+		WHERE (type = $1 OR $1 = '')
+		ORDER BY %s %s, type, id
+		LIMIT $2 OFFSET $3`,
+		filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, itemType, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			m.ErrorLog.Println(err)
+		}
+	}()
+
+	totalRecords := 0
+	items := []*TrashItem{}
+	for rows.Next() {
+		var item TrashItem
+		err := rows.Scan(&totalRecords, &item.Type, &item.ID, &item.Name, &item.DeletedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+// Purge permanently deletes every record that has been in the trash for longer than
+// the retention period, and returns the records it removed. Anything hanging off a
+// purged dorama (cast, genres, seasons, episodes) goes with it through the foreign keys.
+func (m TrashModel) Purge(retention time.Duration) ([]*TrashItem, error) {
+	cutoff := time.Now().Add(-retention)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	purged := []*TrashItem{}
+	for _, query := range []string{
+		`DELETE FROM doramas WHERE deleted_at < $1 RETURNING 'dorama', dorama_id, title, deleted_at`,
+		`DELETE FROM actors WHERE deleted_at < $1 RETURNING 'actor', id, full_name, deleted_at`,
+		`DELETE FROM genres WHERE deleted_at < $1 RETURNING 'genre', genre_id, genre_name, deleted_at`,
+	} {
+		rows, err := tx.QueryContext(ctx, query, cutoff)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var item TrashItem
+			err := rows.Scan(&item.Type, &item.ID, &item.Name, &item.DeletedAt)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			purged = append(purged, &item)
+		}
+
+		err = rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return purged, tx.Commit()
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"time"
 	"crypto/sha256" 
+	"github.com/lib/pq"
 	"github.com/makooster/MCA/pkg/validator"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -23,6 +24,12 @@ type User struct {
 	Email string `json:"email"`
 	Password password `json:"-"`
 	Activated bool `json:"activated"`
+	// MaturityLevel is the oldest minimum age of the titles the user wants to see; see
+	// MaturityAdult.
+	MaturityLevel int `json:"maturity_level"`
+	// PendingEmail is the address the user asked to switch to. It only replaces Email
+	// once the user confirms it with a token from the ScopeEmailChange scope.
+	PendingEmail string `json:"pending_email,omitempty"`
 	Version int `json:"-"`
 }
 
@@ -94,6 +101,7 @@ func ValidateUser(v *validator.Validator, user *User) {
 	
 	// Call the standalone ValidateEmail() helper.
 	ValidateEmail(v, user.Email)
+	ValidateMaturityLevel(v, user.MaturityLevel)
 	
 	// If the plaintext password is not nil, call the standalone
 	// ValidatePasswordPlaintext() helper.
@@ -121,23 +129,70 @@ type UserModel struct {
 // RETURNING clause to read them into the User struct after the insert, in the same way
 // that we did when creating a movie.
 func (m UserModel) Insert(user *User) error {
-	query := `
-	INSERT INTO users (name, email, password_hash, activated)
-	VALUES ($1, $2, $3, $4)
-	RETURNING id, created_at, version`
-	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Activated}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
+	return insertUser(ctx, m.DB, user)
+}
+
+// InsertWithActivation adds a new user with the given permissions, creates their
+// activation token and queues the email that delivers it, all in one transaction. An
+// account is then never left behind without a way to activate it. The new user's ID is
+// added to the email data under "userID", next to the token.
+func (m UserModel) InsertWithActivation(user *User, permissions []string, ttl time.Duration, email *OutboxEmail) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = insertUser(ctx, tx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	err = addPermissionsForUser(ctx, tx, user.ID, permissions...)
+	if err != nil {
+		return nil, err
+	}
+
+	if email.Data == nil {
+		email.Data = make(map[string]interface{})
+	}
+	email.Data["userID"] = user.ID
+
+	token, err := newTokenWithEmail(ctx, tx, user.ID, ttl, ScopeActivation, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, tx.Commit()
+}
+
+// insertUser runs the insert for Insert with q, which is either the connection pool or
+// a transaction.
+func insertUser(ctx context.Context, q queryer, user *User) error {
+	query := `
+	INSERT INTO users (name, email, password_hash, activated, maturity_level)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, version`
+	args := []interface{}{user.Name, user.Email, user.Password.hash, user.Activated, user.MaturityLevel}
+
 	// If the table already contains a record with this email address, then when we try
 	// to perform the insert there will be a violation of the UNIQUE "users_email_key"
 	// constraint that we set up in the previous chapter. We check for this error
 	// specifically, and return custom ErrDuplicateEmail error instead.
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	err := q.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
+		var pqErr *pq.Error
 		switch {
 			case err.Error() == `pq: повторяющееся значение ключа нарушает ограничение уникальности "users_email_key"`:
 				return ErrDuplicateEmail
+			case errors.As(err, &pqErr) && pqErr.Code == "23505":
+				return ErrDuplicateEmail
 			default:
 				return err
 		}
@@ -150,7 +205,7 @@ func (m UserModel) Insert(user *User) error {
 // return one record (or none at all, in which case we return a ErrRecordNotFound error).
 func (m UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-	SELECT id, created_at, name, email, password_hash, activated, version
+	SELECT id, created_at, name, email, password_hash, activated, maturity_level, pending_email, version
 	FROM users
 	WHERE email = $1`
 	var user User
@@ -163,6 +218,8 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.MaturityLevel,
+		&user.PendingEmail,
 		&user.Version,
 	)
 	if err != nil {
@@ -182,9 +239,50 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 // constraint when performing the update, just like we did when inserting the user
 // record originally.
 func (m UserModel) Update(user *User) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return updateUser(ctx, m.DB, user)
+}
+
+// UpdateWithToken saves the user like Update and, in the same transaction, replaces
+// their tokens of the given scope with a new one and queues the email that delivers
+// it. A change that needs confirming is then never saved without its token, nor the
+// token sent for a change that was lost.
+func (m UserModel) UpdateWithToken(user *User, ttl time.Duration, scope string, email *OutboxEmail) (*Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = updateUser(ctx, tx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`, scope, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := newTokenWithEmail(ctx, tx, user.ID, ttl, scope, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, tx.Commit()
+}
+
+// updateUser runs the update for Update with q, which is either the connection pool or
+// a transaction.
+func updateUser(ctx context.Context, q queryer, user *User) error {
 	query := `
 	UPDATE users
-	SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
+	SET name = $1, email = $2, password_hash = $3, activated = $4, maturity_level = $7, pending_email = $8, version = version + 1
 	WHERE id = $5 AND version = $6
 	RETURNING version`
 	args := []interface{}{
@@ -194,14 +292,17 @@ func (m UserModel) Update(user *User) error {
 		user.Activated,
 		user.ID,
 		user.Version,
+		user.MaturityLevel,
+		user.PendingEmail,
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	err := q.QueryRowContext(ctx, query, args...).Scan(&user.Version)
 	if err != nil {
+		var pqErr *pq.Error
 		switch {
 			case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
 				return ErrDuplicateEmail
+			case errors.As(err, &pqErr) && pqErr.Code == "23505":
+				return ErrDuplicateEmail
 			case errors.Is(err, sql.ErrNoRows):
 				return ErrEditConflict
 			default:
@@ -219,7 +320,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 	
 	// Set up the SQL query.
 	query := `
-	SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+	SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.maturity_level, users.pending_email, users.version
 	FROM users
 	INNER JOIN tokens 
 	ON users.id = tokens.user_id
@@ -244,6 +345,8 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.MaturityLevel,
+		&user.PendingEmail,
 		&user.Version,
 	)
 	if err != nil {
@@ -0,0 +1,186 @@
+package model
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/makooster/MCA/pkg/validator"
+)
+
+// Define constants for the states a dorama can be in on a user's watchlist.
+const (
+	WatchStatePlanToWatch = "plan_to_watch"
+	WatchStateWatching    = "watching"
+	WatchStateCompleted   = "completed"
+	WatchStateDropped     = "dropped"
+	WatchStateOnHold      = "on_hold"
+)
+
+var WatchStates = []string{WatchStatePlanToWatch, WatchStateWatching, WatchStateCompleted, WatchStateDropped, WatchStateOnHold}
+
+// WatchlistEntry tracks how far a user has got with a dorama. Title and EpisodeCount
+// come from the dorama and are ignored on writes.
+type WatchlistEntry struct {
+	DoramaID        int       `json:"dorama_id"`
+	Title           string    `json:"title"`
+	State           string    `json:"state"`
+	EpisodesWatched int       `json:"episodes_watched"`
+	EpisodeCount    int       `json:"episode_count"`
+	StartedOn       string    `json:"started_on,omitempty"`
+	FinishedOn      string    `json:"finished_on,omitempty"`
+	Notes           string    `json:"notes"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func ValidateWatchlistEntry(v *validator.Validator, entry *WatchlistEntry) {
+	v.Check(validator.In(entry.State, WatchStates...), "state", "must be one of plan_to_watch, watching, completed, dropped or on_hold")
+	v.Check(entry.EpisodesWatched >= 0, "episodes_watched", "must not be negative")
+	// Doramas whose episodes haven't been entered yet have no count to check against.
+	v.Check(entry.EpisodeCount == 0 || entry.EpisodesWatched <= entry.EpisodeCount, "episodes_watched", "must not be more than the number of episodes")
+	v.Check(entry.StartedOn == "" || validator.Date(entry.StartedOn), "started_on", "must be a date in YYYY-MM-DD format")
+	v.Check(entry.FinishedOn == "" || validator.Date(entry.FinishedOn), "finished_on", "must be a date in YYYY-MM-DD format")
+	v.Check(entry.FinishedOn == "" || entry.FinishedOn >= entry.StartedOn, "finished_on", "must not be before the start date")
+	v.Check(len(entry.Notes) <= 5000, "notes", "must not be more than 5000 bytes long")
+}
+
+type WatchlistModel struct {
+	DB       *sql.DB
+	InfoLog  *log.Logger
+	ErrorLog *log.Logger
+}
+
+// watchlistColumns are selected by every watchlist query, with the names the sort
+// safelist refers to.
+const watchlistColumns = `
+	watchlist_entries.dorama_id, doramas.title AS title, watchlist_entries.state,
+	watchlist_entries.episodes_watched, (SELECT count(*) FROM episodes WHERE episodes.dorama_id = doramas.dorama_id),
+	COALESCE(to_char(watchlist_entries.started_on, 'YYYY-MM-DD'), '') AS started_on,
+	COALESCE(to_char(watchlist_entries.finished_on, 'YYYY-MM-DD'), '') AS finished_on,
+	watchlist_entries.notes, watchlist_entries.created_at AS created_at, watchlist_entries.updated_at AS updated_at`
+
+// GetAll returns a page of a user's watchlist, keeping only entries in the given states
+// if any are given. Doramas in the trash or rated above maturityLevel are left out.
+func (m WatchlistModel) GetAll(userID int64, states []string, maturityLevel int, filters Filters) ([]*WatchlistEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM watchlist_entries
+		INNER JOIN doramas ON doramas.dorama_id = watchlist_entries.dorama_id
+		WHERE watchlist_entries.user_id = $1 AND doramas.deleted_at IS NULL
+		AND doramas.min_age <= $5
+		AND (cardinality($2::text[]) = 0 OR watchlist_entries.state = ANY($2))
+		ORDER BY %s %s, dorama_id
+		LIMIT $3 OFFSET $4`,
+		watchlistColumns, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, pq.Array(states), filters.limit(), filters.offset(), maturityLevel)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*WatchlistEntry{}
+	for rows.Next() {
+		var entry WatchlistEntry
+		err := rows.Scan(&totalRecords, &entry.DoramaID, &entry.Title, &entry.State, &entry.EpisodesWatched, &entry.EpisodeCount,
+			&entry.StartedOn, &entry.FinishedOn, &entry.Notes, &entry.CreatedAt, &entry.UpdatedAt)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return entries, metadata, nil
+}
+
+// Get returns a single watchlist entry, or ErrRecordNotFound if its dorama is in the
+// trash or rated above maturityLevel.
+func (m WatchlistModel) Get(userID int64, doramaID int, maturityLevel int) (*WatchlistEntry, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM watchlist_entries
+		INNER JOIN doramas ON doramas.dorama_id = watchlist_entries.dorama_id
+		WHERE watchlist_entries.user_id = $1 AND watchlist_entries.dorama_id = $2 AND doramas.deleted_at IS NULL
+		AND doramas.min_age <= $3`,
+		watchlistColumns)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entry := &WatchlistEntry{}
+	err := m.DB.QueryRowContext(ctx, query, userID, doramaID, maturityLevel).Scan(&entry.DoramaID, &entry.Title, &entry.State, &entry.EpisodesWatched, &entry.EpisodeCount,
+		&entry.StartedOn, &entry.FinishedOn, &entry.Notes, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return entry, nil
+}
+
+// Set adds a dorama to a user's watchlist, or replaces the entry if it is already there.
+func (m WatchlistModel) Set(userID int64, entry *WatchlistEntry) error {
+	query := `
+		INSERT INTO watchlist_entries (user_id, dorama_id, state, episodes_watched, started_on, finished_on, notes)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::date, NULLIF($6, '')::date, $7)
+		ON CONFLICT (user_id, dorama_id)
+		DO UPDATE SET state = EXCLUDED.state, episodes_watched = EXCLUDED.episodes_watched, started_on = EXCLUDED.started_on,
+			finished_on = EXCLUDED.finished_on, notes = EXCLUDED.notes, updated_at = NOW()
+		RETURNING created_at, updated_at`
+
+	args := []interface{}{userID, entry.DoramaID, entry.State, entry.EpisodesWatched, entry.StartedOn, entry.FinishedOn, entry.Notes}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23503":
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m WatchlistModel) Delete(userID int64, doramaID int) error {
+	query := `
+		DELETE FROM watchlist_entries
+		WHERE user_id = $1 AND dorama_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, doramaID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
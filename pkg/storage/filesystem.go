@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileSystem stores objects as files under a root directory. It doesn't serve them;
+// the application is expected to serve Root under BaseURL.
+type FileSystem struct {
+	Root    string
+	BaseURL string
+}
+
+// NewFileSystem creates the root directory if needed and returns a FileSystem storage
+// rooted there.
+func NewFileSystem(root, baseURL string) (*FileSystem, error) {
+	err := os.MkdirAll(root, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSystem{Root: root, BaseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Put writes the object to a temporary file first and renames it into place, so that
+// readers never see a half-written file.
+func (s *FileSystem) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *FileSystem) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileSystem) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+// path maps a key onto a file under the root directory, refusing keys that would
+// escape it.
+func (s *FileSystem) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(clean)), nil
+}
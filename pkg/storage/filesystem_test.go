@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSystemPath(t *testing.T) {
+	s := &FileSystem{Root: "/srv/uploads"}
+
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"doramas/12/poster", "/srv/uploads/doramas/12/poster", false},
+		{"poster", "/srv/uploads/poster", false},
+		{"", "", true},
+		{"/", "", true},
+		{"/doramas/12/poster", "", true},
+		{"../etc/passwd", "", true},
+		{"doramas/../../etc/passwd", "", true},
+		{"doramas/./12/poster", "", true},
+		{"doramas//12", "", true},
+		{"doramas/12/", "", true},
+		{"..", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := s.path(tt.key)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("path(%q): got error %v; want error %t", tt.key, err, tt.wantErr)
+			continue
+		}
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("path(%q) = %q; want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestFileSystemPutAndDelete(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewFileSystem(root, "/app/images/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	err = s.Put(ctx, "doramas/12/poster", strings.NewReader("poster"), "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "doramas", "12", "poster"))
+	if err != nil || string(data) != "poster" {
+		t.Errorf("got %q, %v; want the stored poster", data, err)
+	}
+	if got := s.URL("doramas/12/poster"); got != "/app/images/doramas/12/poster" {
+		t.Errorf("got URL %q", got)
+	}
+
+	err = s.Put(ctx, "../outside", strings.NewReader("x"), "image/jpeg")
+	if err == nil {
+		t.Error("stored a file outside the root")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "outside")); err == nil {
+		t.Error("file written outside the root")
+	}
+
+	for i := 0; i < 2; i++ {
+		err = s.Delete(ctx, "doramas/12/poster")
+		if err != nil {
+			t.Fatalf("delete %d: %v", i+1, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "doramas", "12", "poster")); !os.IsNotExist(err) {
+		t.Errorf("poster still there after delete: %v", err)
+	}
+}
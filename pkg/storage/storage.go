@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// Storage is a place to keep uploaded files such as posters and photos. Keys are
+// slash-separated paths like "doramas/12/poster"; each backend decides how they map
+// onto its own layout.
+type Storage interface {
+	// Put stores the contents of r under key, replacing anything already there.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Delete removes the object stored under key. Deleting a missing key is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients can fetch the object from.
+	URL(key string) string
+}
@@ -1,6 +1,9 @@
 package validator
 
-import "regexp"
+import (
+	"regexp"
+	"time"
+)
 
 var (
 	// EmailRX is a regex for sanity checking the format of email addresses.
@@ -53,13 +56,19 @@ func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
 
-// Unique returns true if all string values in a slice are unique.
-func Unique(values []string) bool {
-	uniqueValues := make(map[string]bool)
+// Unique returns true if all values in a slice are unique.
+func Unique[T comparable](values []T) bool {
+	uniqueValues := make(map[T]bool)
 
 	for _, value := range values {
 		uniqueValues[value] = true
 	}
 
 	return len(values) == len(uniqueValues)
-}
\ No newline at end of file
+}
+
+// Date returns true if a string value is a calendar date in YYYY-MM-DD format.
+func Date(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}